@@ -3,7 +3,7 @@ package gde
 // Accumulator is an interface for "accumulating" metrics from plugin(s).
 // The metrics are sent down a channel shared between all plugins.
 type Accumulator interface {
-	AddOutput(dir string, valueType ValueType, action Action, title string, content []byte)
+	AddOutput(dir string, valueType ValueType, action Action, title string, folder string, content []byte, metadata Metadata)
 
 	AddError(err error)
 }
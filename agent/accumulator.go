@@ -24,25 +24,78 @@ func NewAccumulator(
 type accumulator struct {
 	metrics chan gde.Metric
 	maker   MetricMaker
+
+	// state is set when only changed runs are to be sent to the outputs,
+	// the metrics of a run are then held back in runs until it finishes.
+	// id tells the input apart from the other inputs of the same name.
+	state *stateStore
+	id    string
+	runs  map[string][]gde.Metric
 }
 
-func (ac *accumulator) AddOutput(dir string, valueType gde.ValueType, action gde.Action, title string, content []byte) {
+func (ac *accumulator) AddOutput(dir string, valueType gde.ValueType, action gde.Action, title string, folder string, content []byte, metadata gde.Metadata) {
 	if action != "" {
 		switch action {
 		case gde.ActionCreate:
 			if dir != "" && valueType != "" && title != "" && len(content) > 0 {
-				ac.metrics <- metric.New(dir, valueType, action, title, content)
+				ac.send(metric.New(dir, valueType, action, title, folder, content, metadata))
 			}
 			break
 		case gde.ActionFinish:
 			if dir != "" {
-				ac.metrics <- metric.New(dir, valueType, action, title, content)
+				ac.send(metric.New(dir, valueType, action, title, folder, content, metadata))
 			}
 			break
 		}
 	}
 }
 
+// send passes the metric down the channel, or holds it back until its run
+// finishes when a state store is set
+func (ac *accumulator) send(m gde.Metric) {
+	if ac.state == nil {
+		ac.metrics <- m
+		return
+	}
+
+	if m.Action() == gde.ActionCreate {
+		if ac.runs == nil {
+			ac.runs = make(map[string][]gde.Metric)
+		}
+		ac.runs[m.Dir()] = append(ac.runs[m.Dir()], m)
+		return
+	}
+
+	run := ac.runs[m.Dir()]
+	delete(ac.runs, m.Dir())
+
+	hashes := make(map[string]string, len(run))
+	for _, rm := range run {
+		hashes[objectKey(rm)] = objectHash(rm)
+	}
+	key := runKey(ac.id, m.Dir())
+	if !ac.state.changed(key, hashes) {
+		log.Printf("I! No changes in run %s of plugin [%s], skipping it", m.Dir(), ac.maker.Name())
+		return
+	}
+
+	// the hashes are recorded once the outputs wrote the run
+	ac.state.hold(m.Dir(), key, hashes)
+	for _, rm := range run {
+		ac.metrics <- rm
+	}
+	ac.metrics <- m
+}
+
+// discardRuns drops the metrics held back for the runs which did not
+// finish, the input returned without finishing them
+func (ac *accumulator) discardRuns() {
+	for dir := range ac.runs {
+		log.Printf("W! Dropping run %s of plugin [%s], it never finished", dir, ac.maker.Name())
+		delete(ac.runs, dir)
+	}
+}
+
 // AddError passes a runtime error to the accumulator.
 // The error will be tagged with the plugin name and written to the log.
 func (ac *accumulator) AddError(err error) {
@@ -14,6 +14,10 @@ import (
 // Agent runs GDE and collects data based on the given config
 type Agent struct {
 	Config *config.Config
+
+	// state holds the object hashes of the previous runs, it is only set
+	// when runs without changes are to be skipped
+	state *stateStore
 }
 
 // NewAgent returns an Agent struct based off the given Config
@@ -21,6 +25,13 @@ func NewAgent(config *config.Config) (*Agent, error) {
 	a := &Agent{
 		Config: config,
 	}
+	if config.Agent.OnlyOnChange {
+		state, err := newStateStore(config.Agent.StateFile)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to load state file %s, %s", config.Agent.StateFile, err)
+		}
+		a.state = state
+	}
 	return a, nil
 }
 
@@ -65,6 +76,8 @@ func (a *Agent) gatherer(
 	defer panicRecover(input)
 
 	acc := NewAccumulator(input, metricC)
+	acc.state = a.state
+	acc.id = a.inputID(input)
 
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
@@ -81,6 +94,25 @@ func (a *Agent) gatherer(
 	}
 }
 
+// inputID returns the name of input, suffixed with its position among the
+// inputs of the same name when there are several
+func (a *Agent) inputID(input *config.RunningInput) string {
+	n, position := 0, 0
+	for _, in := range a.Config.Inputs {
+		if in.Name() != input.Name() {
+			continue
+		}
+		if in == input {
+			position = n
+		}
+		n++
+	}
+	if n == 1 {
+		return input.Name()
+	}
+	return fmt.Sprintf("%s#%d", input.Name(), position)
+}
+
 // gatherWithTimeout gathers from the given input, with the given timeout.
 //   when the given timeout is reached, gatherWithTimeout logs an error message
 //   but continues waiting for it to return. This is to avoid leaving behind
@@ -105,6 +137,7 @@ func gatherWithTimeout(
 			if err != nil {
 				acc.AddError(err)
 			}
+			acc.discardRuns()
 			return
 		case <-ticker.C:
 			err := fmt.Errorf("took longer to collect than collection interval (%s)",
@@ -181,8 +214,15 @@ func (a *Agent) flusher(shutdown chan struct{}, metricC chan gde.Metric) error {
 			case <-shutdown:
 				return
 			case m := <-metricC:
+				written := true
 				for _, o := range a.Config.Outputs {
-					o.Output.Write(m)
+					if err := o.Output.Write(m); err != nil {
+						log.Printf("E! Error writing to output [%s]: %s\n", o.Name, err.Error())
+						written = false
+					}
+				}
+				if a.state != nil {
+					a.state.written(m, written)
 				}
 			}
 		}
@@ -194,8 +234,8 @@ func (a *Agent) flusher(shutdown chan struct{}, metricC chan gde.Metric) error {
 func (a *Agent) Run(shutdown chan struct{}) error {
 	var wg sync.WaitGroup
 
-	log.Printf("I! Agent Config: Interval:%s, Quiet:%#v \n",
-		a.Config.Agent.Interval, a.Config.Agent.Quiet)
+	log.Printf("I! Agent Config: Interval:%s, Quiet:%#v, OnlyOnChange:%#v \n",
+		a.Config.Agent.Interval, a.Config.Agent.Quiet, a.Config.Agent.OnlyOnChange)
 
 	// channel shared between all input threads for accumulating metrics
 	metricC := make(chan gde.Metric, 100)
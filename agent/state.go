@@ -0,0 +1,159 @@
+package agent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/vikramjakhr/grafana-dashboard-exporter"
+)
+
+// stateStore records the SHA-256 of every object of the last run of each
+// org written by the outputs, so that runs without any change can be
+// skipped. Orgs are keyed by the input they are exported by, see runKey.
+type stateStore struct {
+	// path of the file the state is persisted to, empty keeps the state
+	// in memory only
+	path string
+
+	mu sync.Mutex
+	// orgs maps an org to the hashes of its objects keyed by object key
+	orgs map[string]map[string]string
+	// pending holds the runs sent to the outputs by directory, recorded
+	// once the outputs wrote them
+	pending map[string]*pendingRun
+}
+
+// pendingRun is a run sent to the outputs, failed being set as soon as an
+// output fails to write one of its metrics
+type pendingRun struct {
+	key    string
+	hashes map[string]string
+	failed bool
+}
+
+// newStateStore loads the state persisted at path, if any
+func newStateStore(path string) (*stateStore, error) {
+	s := &stateStore{
+		path:    path,
+		orgs:    make(map[string]map[string]string),
+		pending: make(map[string]*pendingRun),
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.orgs); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// changed reports whether hashes differ from the ones recorded for key
+func (s *stateStore) changed(key string, hashes map[string]string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, ok := s.orgs[key]
+	if !ok || len(previous) != len(hashes) {
+		return true
+	}
+	for key, hash := range hashes {
+		if previous[key] != hash {
+			return true
+		}
+	}
+	return false
+}
+
+// hold keeps the hashes of the run of dir back until the outputs wrote it
+func (s *stateStore) hold(dir, key string, hashes map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending[dir] = &pendingRun{key: key, hashes: hashes}
+}
+
+// written tells whether every output wrote m. The hashes of its run are
+// recorded once its finish metric is written, unless an output failed to
+// write any of its metrics, so that the run is sent again next time.
+func (s *stateStore) written(m gde.Metric, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	run, held := s.pending[m.Dir()]
+	if !held {
+		return
+	}
+	if !ok {
+		run.failed = true
+	}
+	if m.Action() != gde.ActionFinish {
+		return
+	}
+	delete(s.pending, m.Dir())
+	if run.failed {
+		log.Printf("W! Run %s was not written by every output, it will be sent again", m.Dir())
+		return
+	}
+	s.orgs[run.key] = run.hashes
+	if err := s.save(); err != nil {
+		log.Printf("E! Unable to save the state of run %s: %s", m.Dir(), err)
+	}
+}
+
+// save persists the state, s.mu being held
+func (s *stateStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.orgs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	// write to a temporary file first, so a crash never leaves a
+	// truncated state behind
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// objectKey identifies an object across runs of the same org, by uid when
+// it has one
+func objectKey(m gde.Metric) string {
+	if m.UID() != "" {
+		return strings.Join([]string{string(m.Type()), m.UID()}, "/")
+	}
+	return strings.Join([]string{string(m.Type()), m.Folder(), m.Title()}, "/")
+}
+
+// objectHash returns the hex encoded SHA-256 of the object content
+func objectHash(m gde.Metric) string {
+	sum := sha256.Sum256(m.Content())
+	return hex.EncodeToString(sum[:])
+}
+
+// runKey identifies the org of the <Org>@<timestamp> run directory dir
+// across runs of the input with the given id, inputs exporting orgs of the
+// same name from different instances
+func runKey(input, dir string) string {
+	return input + "/" + strings.SplitN(dir, "@", 2)[0]
+}
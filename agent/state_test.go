@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vikramjakhr/grafana-dashboard-exporter"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/metric"
+)
+
+// sendRun holds the hashes of a run of dashboard under key and tells the
+// store whether the outputs wrote its dashboard and finish metrics
+func sendRun(s *stateStore, key, dir, dashboard string, ok bool) map[string]string {
+	m := metric.New(dir, gde.TypeDashboard, gde.ActionCreate, "API", "", []byte(dashboard),
+		gde.Metadata{gde.MetaUID: "abc"})
+	hashes := map[string]string{objectKey(m): objectHash(m)}
+	s.hold(dir, key, hashes)
+	s.written(m, ok)
+	s.written(metric.New(dir, "", gde.ActionFinish, "", "", nil, nil), true)
+	return hashes
+}
+
+func TestStateStore(t *testing.T) {
+	s, err := newStateStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := runKey("grafana-1", "MainOrg@2019-April-7T10:00:00")
+
+	hashes := sendRun(s, key, "MainOrg@2019-April-7T10:00:00", `{"title": "API"}`, false)
+	if !s.changed(key, hashes) {
+		t.Errorf("a run an output failed to write was recorded")
+	}
+	if len(s.pending) != 0 {
+		t.Errorf("%d runs still pending after their finish metric", len(s.pending))
+	}
+
+	hashes = sendRun(s, key, "MainOrg@2019-April-7T11:00:00", `{"title": "API"}`, true)
+	if s.changed(key, hashes) {
+		t.Errorf("the written run was not recorded")
+	}
+	if other := runKey("grafana-2", "MainOrg@2019-April-7T11:00:00"); !s.changed(other, hashes) {
+		t.Errorf("the run of an org of the same name of another input was recorded under %s", other)
+	}
+
+	m := metric.New("MainOrg@2019-April-7T12:00:00", gde.TypeDashboard, gde.ActionCreate, "API", "",
+		[]byte(`{"title": "API v2"}`), gde.Metadata{gde.MetaUID: "abc"})
+	if !s.changed(key, map[string]string{objectKey(m): objectHash(m)}) {
+		t.Errorf("a changed dashboard was not reported")
+	}
+	if !s.changed(key, map[string]string{}) {
+		t.Errorf("a removed dashboard was not reported")
+	}
+
+	// metrics of runs which are not held are ignored
+	s.written(metric.New("MainOrg@2019-April-7T13:00:00", "", gde.ActionFinish, "", "", nil, nil), true)
+}
+
+func TestStateStorePersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gde-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state", "state.json")
+
+	s, err := newStateStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := runKey("grafana-1", "MainOrg@2019-April-7T10:00:00")
+	hashes := sendRun(s, key, "MainOrg@2019-April-7T10:00:00", `{"title": "API"}`, true)
+
+	loaded, err := newStateStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.changed(key, hashes) {
+		t.Errorf("the state saved to %s was not loaded", path)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newStateStore(path); err == nil {
+		t.Errorf("loading a corrupted state returned no error")
+	}
+}
@@ -0,0 +1,304 @@
+package backup
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/vikramjakhr/grafana-dashboard-exporter"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/manifest"
+)
+
+// Object is a single exported json file found in a backup
+type Object struct {
+	Type gde.ValueType
+	// Path is the file path relative to the type directory, ie,
+	// "Dashboards/Team/Overview.json" has the path "Team/Overview.json"
+	Path    string
+	Content []byte
+	// Folder is the title of the folder the object is placed in, as
+	// listed in the manifest of the backup
+	Folder string
+}
+
+// Backup is the in-memory representation of a single run written by the
+// file output, either as a directory or as a zip or tar archive.
+type Backup struct {
+	// Dir is the run directory name, ie, "MainOrg@2019-April-7T10:00:00"
+	Dir     string
+	Objects []Object
+	// Manifest is the content of the manifest of the run, nil for backups
+	// written before runs had a manifest
+	Manifest []byte
+}
+
+// Open reads a backup from a run directory or from a zip, tar.gz or tar.zst
+// archive
+func Open(path string) (*Backup, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	var b *Backup
+	if info.IsDir() {
+		b, err = openDir(path)
+	} else if strings.EqualFold(filepath.Ext(path), ".zip") {
+		b, err = openZip(path)
+	} else if strings.HasSuffix(strings.ToLower(path), ".tar.gz") {
+		b, err = openTarGz(path)
+	} else if strings.HasSuffix(strings.ToLower(path), ".tar.zst") {
+		b, err = openTarZst(path)
+	} else if ext := filepath.Ext(path); ext == ".enc" || ext == ".age" || ext == ".gpg" {
+		return nil, fmt.Errorf("%s is encrypted, decrypt it first with 'gde decrypt'", path)
+	} else {
+		return nil, fmt.Errorf("%s is neither a backup directory nor a zip or tar file", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(b.Objects) == 0 {
+		return nil, fmt.Errorf("no objects found in backup %s", path)
+	}
+	if err := b.annotate(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(b.Objects, func(i, j int) bool {
+		return b.Objects[i].Path < b.Objects[j].Path
+	})
+	return b, nil
+}
+
+// ObjectsOf returns the objects of the given type
+func (b *Backup) ObjectsOf(valueType gde.ValueType) []Object {
+	var objects []Object
+	for _, o := range b.Objects {
+		if o.Type == valueType {
+			objects = append(objects, o)
+		}
+	}
+	return objects
+}
+
+func openDir(path string) (*Backup, error) {
+	root := filepath.Clean(path)
+	b := &Backup{Dir: filepath.Base(root)}
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		content, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		b.add(filepath.ToSlash(rel), content)
+		return nil
+	})
+	return b, err
+}
+
+func openZip(path string) (*Backup, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var entries []entry
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{f.Name, content})
+	}
+	return fromArchive(entries, strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))), nil
+}
+
+func openTarGz(path string) (*Backup, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	return readTar(gz, tarDir(path, ".tar.gz"))
+}
+
+// openTarZst decompresses the tarball with the zstd binary
+func openTarZst(path string) (*Backup, error) {
+	cmd := exec.Command("zstd", "--quiet", "--decompress", "--stdout", path)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	b, err := readTar(out, tarDir(path, ".tar.zst"))
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, err
+	}
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("zstd: %s %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return b, nil
+}
+
+// tarDir returns the run directory of a tarball, its name without ext
+func tarDir(path, ext string) string {
+	name := filepath.Base(path)
+	return name[:len(name)-len(ext)]
+}
+
+func readTar(r io.Reader, dir string) (*Backup, error) {
+	var entries []entry
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return fromArchive(entries, dir), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+			continue
+		}
+		content, err := ioutil.ReadAll(archive)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{header.Name, content})
+	}
+}
+
+// entry is a file of an archive
+type entry struct {
+	name    string
+	content []byte
+}
+
+// fromArchive returns the backup of the entries of an archive. Archives
+// hold the run directory at their root, which is kept whatever the archive
+// is renamed to. The name of the archive without its extension is only
+// the run directory of archives holding the files of the run directly.
+func fromArchive(entries []entry, name string) *Backup {
+	b := &Backup{Dir: name}
+	prefix := ""
+	if dir := archiveDir(entries); dir != "" {
+		b.Dir = dir
+		prefix = dir + "/"
+	}
+	for _, e := range entries {
+		b.add(strings.TrimPrefix(e.name, prefix), e.content)
+	}
+	return b
+}
+
+// archiveDir returns the run directory all the entries are placed in, empty
+// when they are not in a single one. A top level directory which is not a
+// <Org>@<timestamp> run directory is only the run directory when it holds
+// the manifest, it is the type directory of an archive without one
+// otherwise.
+func archiveDir(entries []entry) string {
+	dir := ""
+	hasManifest := false
+	for _, e := range entries {
+		parts := strings.SplitN(e.name, "/", 2)
+		if len(parts) != 2 || (dir != "" && parts[0] != dir) {
+			return ""
+		}
+		dir = parts[0]
+		hasManifest = hasManifest || parts[1] == manifest.Name
+	}
+	if !hasManifest && !strings.Contains(dir, "@") {
+		return ""
+	}
+	return dir
+}
+
+// Files returns the content of the objects keyed by their path relative to
+// the run directory, as listed in the manifest
+func (b *Backup) Files() map[string][]byte {
+	files := make(map[string][]byte, len(b.Objects))
+	for _, o := range b.Objects {
+		files[string(o.Type)+"s/"+o.Path] = o.Content
+	}
+	return files
+}
+
+// annotate completes the objects with what the manifest tells about them
+func (b *Backup) annotate() error {
+	if b.Manifest == nil {
+		return nil
+	}
+	m, err := manifest.Parse(b.Manifest)
+	if err != nil {
+		return err
+	}
+	if m.Dir != "" {
+		// the backup may have been renamed
+		b.Dir = m.Dir
+	}
+	folders := make(map[string]string, len(m.Objects))
+	for _, o := range m.Objects {
+		folders[o.Path] = o.Folder
+	}
+	for i := range b.Objects {
+		o := &b.Objects[i]
+		o.Folder = folders[string(o.Type)+"s/"+o.Path]
+	}
+	return nil
+}
+
+// add registers a file found at name, relative to the run directory.
+// Files outside of a "<Type>s/" directory other than the manifest are
+// ignored.
+func (b *Backup) add(name string, content []byte) {
+	if name == manifest.Name {
+		b.Manifest = content
+		return
+	}
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) != 2 || !strings.HasSuffix(parts[1], ".json") {
+		return
+	}
+	b.Objects = append(b.Objects, Object{
+		Type:    gde.ValueType(strings.TrimSuffix(parts[0], "s")),
+		Path:    parts[1],
+		Content: content,
+	})
+}
@@ -0,0 +1,153 @@
+package backup
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/vikramjakhr/grafana-dashboard-exporter"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/internal/archive"
+)
+
+const runDir = "MainOrg@2019-April-7T10:00:00"
+
+var runManifest = `{"dir": "` + runDir + `", "count": 2, "objects": [
+	{"type": "Dashboard", "title": "Overview", "folder": "Team A", "path": "Dashboards/TeamA/Overview.json"},
+	{"type": "Datasource", "title": "prom", "path": "Datasources/prom.json"}
+]}`
+
+func entries(withManifest bool) []archive.Entry {
+	e := []archive.Entry{
+		{Name: "Dashboards/TeamA/Overview.json", Content: []byte(`{"dashboard": {"title": "Overview"}}`)},
+		{Name: "Datasources/prom.json", Content: []byte(`{"name": "prom"}`)},
+	}
+	if withManifest {
+		e = append(e, archive.Entry{Name: "manifest.json", Content: []byte(runManifest)})
+	}
+	return e
+}
+
+// write writes the run as format to dir/name, returning its path
+func write(t *testing.T, dir, name, format string, withManifest bool) string {
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := archive.NewFormat(format, 0).Write(f, runDir, entries(withManifest)); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func check(t *testing.T, name string, b *Backup, withManifest bool) {
+	if b.Dir != runDir {
+		t.Errorf("%s: Dir = %q, want %q", name, b.Dir, runDir)
+	}
+	if withManifest != (b.Manifest != nil) {
+		t.Errorf("%s: manifest found = %v, want %v", name, b.Manifest != nil, withManifest)
+	}
+	dashboards := b.ObjectsOf(gde.TypeDashboard)
+	if len(dashboards) != 1 || dashboards[0].Path != "TeamA/Overview.json" {
+		t.Errorf("%s: dashboards = %+v, want TeamA/Overview.json", name, dashboards)
+	} else if withManifest && dashboards[0].Folder != "Team A" {
+		t.Errorf("%s: dashboard folder = %q, want %q", name, dashboards[0].Folder, "Team A")
+	}
+	if datasources := b.ObjectsOf(gde.TypeDatasource); len(datasources) != 1 {
+		t.Errorf("%s: %d datasources, want 1", name, len(datasources))
+	}
+	if len(b.Objects) != 2 {
+		t.Errorf("%s: %d objects, want 2", name, len(b.Objects))
+	}
+}
+
+func TestOpenArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gde-backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	formats := []string{archive.FormatZip, archive.FormatTarGz}
+	if _, err := exec.LookPath("zstd"); err == nil {
+		formats = append(formats, archive.FormatTarZst)
+	}
+	for _, format := range formats {
+		for _, withManifest := range []bool{true, false} {
+			for _, name := range []string{runDir + "." + format, "backup." + format} {
+				path := write(t, dir, name, format, withManifest)
+				b, err := Open(path)
+				if err != nil {
+					t.Errorf("%s: %s", name, err)
+					continue
+				}
+				check(t, name, b, withManifest)
+			}
+		}
+	}
+}
+
+func TestOpenDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gde-backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a renamed directory is told apart by its manifest
+	root := filepath.Join(dir, "backup")
+	for _, e := range entries(true) {
+		path := filepath.Join(root, filepath.FromSlash(e.Name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, e.Content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := Open(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(t, "backup", b, true)
+}
+
+func TestOpenEncrypted(t *testing.T) {
+	for _, name := range []string{"run.zip.enc", "run.tar.gz.age", "run.tar.zst.gpg"} {
+		path := filepath.Join(os.TempDir(), name)
+		if err := ioutil.WriteFile(path, []byte("sealed"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Open(path); err == nil {
+			t.Errorf("Open(%s) returned no error", name)
+		}
+		os.Remove(path)
+	}
+}
+
+func TestArchiveDir(t *testing.T) {
+	tests := []struct {
+		name  string
+		names []string
+		want  string
+	}{
+		{"run directory", []string{runDir + "/Dashboards/a.json", runDir + "/manifest.json"}, runDir},
+		{"run directory without manifest", []string{runDir + "/Dashboards/a.json"}, runDir},
+		{"renamed run directory with manifest", []string{"backup/Dashboards/a.json", "backup/manifest.json"}, "backup"},
+		{"files of the run at the root", []string{"Dashboards/a.json", "manifest.json"}, ""},
+		{"single type at the root", []string{"Dashboards/a.json", "Dashboards/b.json"}, ""},
+		{"several directories", []string{runDir + "/Dashboards/a.json", "Other@x/Dashboards/a.json"}, ""},
+	}
+	for _, tt := range tests {
+		var e []entry
+		for _, n := range tt.names {
+			e = append(e, entry{name: n})
+		}
+		if got := archiveDir(e); got != tt.want {
+			t.Errorf("%s: archiveDir() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
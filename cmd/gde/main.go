@@ -6,9 +6,14 @@ import (
 	"os"
 	"strings"
 
+	"github.com/vikramjakhr/grafana-dashboard-exporter"
 	"github.com/vikramjakhr/grafana-dashboard-exporter/agent"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/backup"
 	"github.com/vikramjakhr/grafana-dashboard-exporter/config"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/internal"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/internal/encryption"
 	"github.com/vikramjakhr/grafana-dashboard-exporter/logger"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/manifest"
 	"github.com/vikramjakhr/grafana-dashboard-exporter/plugins/inputs"
 	_ "github.com/vikramjakhr/grafana-dashboard-exporter/plugins/inputs/all"
 	"github.com/vikramjakhr/grafana-dashboard-exporter/plugins/outputs"
@@ -16,6 +21,7 @@ import (
 	"log"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
 var fDebug = flag.Bool("debug", false,
@@ -40,6 +46,10 @@ var fOutputList = flag.Bool("output-list", false,
 	"print available output plugins.")
 var fConfigDirectory = flag.String("config-directory", "",
 	"directory containing additional *.conf files")
+var fPassphraseFile = flag.String("passphrase-file", "",
+	"file holding the passphrase of aes-gcm encrypted archives, for 'gde decrypt'")
+var fIdentity = flag.String("identity", "",
+	"age identity file of age encrypted archives, for 'gde decrypt'")
 
 var (
 	nextVersion = "1.0.0"
@@ -75,11 +85,17 @@ The commands & flags are:
 
   config              print out full sample configuration to stdout
   version             print the version to stdout
+  restore <backup>    push a backup directory or zip back to the grafana
+                      host of the configured input
+  decrypt <in> [out]  decrypt an encrypted archive, to stdout without out
+  verify <backup>     check a backup directory or archive against its manifest
 
   --config <file>     configuration file to load
   --test              gather metrics once, print them to stdout, and exit
   --usage             print usage for a plugin, ie, 'gde --usage s3'
   --quiet             run in quiet mode
+  --passphrase-file   passphrase of aes-gcm archives, GDE_PASSPHRASE otherwise
+  --identity          age identity file of age archives
 
 Examples:
 
@@ -91,15 +107,129 @@ Examples:
 
   # run gde with all plugins defined in config file
   gde --config gde.conf
+
+  # restore a backup written by the file output
+  gde --config gde.conf restore /tmp/gde/MainOrg@2019-April-7T10:00:00.zip
+
+  # verify the checksums of a backup
+  gde verify /tmp/gde/MainOrg@2019-April-7T10:00:00.tar.gz
+
+  # decrypt an archive written with the aes-gcm encryption
+  gde --passphrase-file passphrase decrypt MainOrg@2019-April-7T10:00:00.zip.enc MainOrg@2019-April-7T10:00:00.zip
 `
 
 func usageExit(rc int) {
-	fmt.Println(usage)
+	fmt.Print(usage)
 	os.Exit(rc)
 }
 
 var stop chan struct{}
 
+// restore loads the config file and restores the backup at path through
+// the configured input that supports it. It refuses to restore when several
+// inputs support it, a single backup would otherwise overwrite every
+// configured instance.
+func restore(path string, inputFilters []string) {
+	c := config.NewConfig()
+	c.InputFilters = inputFilters
+	err := c.LoadConfig(*fConfig)
+	if err != nil {
+		log.Fatal("E! " + err.Error())
+	}
+
+	logger.SetupLogging(
+		c.Agent.Debug || *fDebug,
+		c.Agent.Quiet || *fQuiet,
+		"",
+	)
+
+	var restorers []*config.RunningInput
+	for _, input := range c.Inputs {
+		if _, ok := input.Input.(gde.Restorer); ok {
+			restorers = append(restorers, input)
+		}
+	}
+	switch len(restorers) {
+	case 0:
+		log.Fatalf("E! Error: no input supporting restore found, did you provide a valid config file?")
+	case 1:
+	default:
+		log.Fatalf("E! Error: %d inputs supporting restore found, restore pushes to a single one. "+
+			"Narrow them down with --input-filter, or use a config file with a single one.", len(restorers))
+	}
+
+	input := restorers[0]
+	log.Printf("I! Restoring %s with %s", path, input.Name())
+	if err := input.Input.(gde.Restorer).Restore(path); err != nil {
+		log.Fatalf("E! Restore with %s failed: %s", input.Name(), err)
+	}
+}
+
+// decrypt writes the plaintext of the encrypted archive in to out, or to
+// stdout when out is empty
+func decrypt(in, out string) {
+	opts := encryption.DecryptOptions{
+		Passphrase: os.Getenv("GDE_PASSPHRASE"),
+		Identity:   *fIdentity,
+	}
+	if *fPassphraseFile != "" {
+		passphrase, err := encryption.ReadPassphrase(*fPassphraseFile)
+		if err != nil {
+			log.Fatal("E! " + err.Error())
+		}
+		opts.Passphrase = passphrase
+	}
+
+	r, err := os.Open(in)
+	if err != nil {
+		log.Fatal("E! " + err.Error())
+	}
+	defer r.Close()
+
+	w := os.Stdout
+	if out != "" {
+		if w, err = os.OpenFile(out, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600); err != nil {
+			log.Fatal("E! " + err.Error())
+		}
+	}
+	err = encryption.Decrypt(r, w, opts)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		if out != "" {
+			os.Remove(out)
+		}
+		log.Fatalf("E! Unable to decrypt %s: %s", in, err)
+	}
+}
+
+// verify checks the objects of the backup at path against its manifest
+func verify(path string) {
+	b, err := backup.Open(path)
+	if err != nil {
+		log.Fatal("E! " + err.Error())
+	}
+	if b.Manifest == nil {
+		log.Fatalf("E! %s has no %s, it was written by an older GDE", path, manifest.Name)
+	}
+	m, err := manifest.Parse(b.Manifest)
+	if err != nil {
+		log.Fatalf("E! %s: %s", path, err)
+	}
+
+	problems := m.Verify(b.Files())
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		log.Fatalf("E! %s failed verification, %d problems found", path, len(problems))
+	}
+	fmt.Printf("%s: %d objects verified, exported from %s (grafana %s) by GDE %s on %s\n",
+		path, m.Count, m.Grafana.Host, m.Grafana.Version, m.GDEVersion,
+		m.Finished.Format(time.RFC3339))
+}
+
 func reloadLoop(
 	stop chan struct{},
 	inputFilters []string,
@@ -163,7 +293,7 @@ func reloadLoop(
 		}
 
 		shutdown := make(chan struct{})
-		signals := make(chan os.Signal)
+		signals := make(chan os.Signal, 1)
 		signal.Notify(signals, os.Interrupt, syscall.SIGHUP)
 		go func() {
 			select {
@@ -211,6 +341,7 @@ func reloadLoop(
 func main() {
 	flag.Usage = func() { usageExit(0) }
 	flag.Parse()
+	internal.SetVersion(displayVersion())
 	args := flag.Args()
 
 	inputFilters, outputFilters := []string{}, []string{}
@@ -232,6 +363,28 @@ func main() {
 				outputFilters,
 			)
 			return
+		case "restore":
+			if len(args) < 2 {
+				usageExit(1)
+			}
+			restore(args[1], inputFilters)
+			return
+		case "decrypt":
+			if len(args) < 2 {
+				usageExit(1)
+			}
+			out := ""
+			if len(args) > 2 {
+				out = args[2]
+			}
+			decrypt(args[1], out)
+			return
+		case "verify":
+			if len(args) < 2 {
+				usageExit(1)
+			}
+			verify(args[1])
+			return
 		}
 	}
 
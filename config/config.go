@@ -64,6 +64,13 @@ var header = `# GDE Configuration
   ## Specify the log file name. The empty string means to log to stderr.
   logfile = ""
 
+  ## Skip a run when none of its objects changed since the previous run.
+  only_on_change = false
+  ## File the object hashes of the previous runs are kept in. The empty
+  ## string keeps them in memory only, so the first run after a restart is
+  ## never skipped.
+  state_file = ""
+
 
 ###############################################################################
 #                            OUTPUT PLUGINS                                   #
@@ -145,13 +152,21 @@ type AgentConfig struct {
 
 	// Quiet is the option for running in quiet mode
 	Quiet bool
+
+	// OnlyOnChange skips the runs whose objects did not change since the
+	// previous run
+	OnlyOnChange bool `toml:"only_on_change"`
+
+	// StateFile is the file the object hashes of the previous runs are
+	// persisted to
+	StateFile string `toml:"state_file"`
 }
 
 func PrintSampleConfig(
 	inputFilters []string,
 	outputFilters []string,
 ) {
-	fmt.Printf(header)
+	fmt.Print(header)
 
 	// print output plugins
 	if len(outputFilters) != 0 {
@@ -170,7 +185,7 @@ func PrintSampleConfig(
 	}
 
 	// print input plugins
-	fmt.Printf(inputHeader)
+	fmt.Print(inputHeader)
 	if len(inputFilters) != 0 {
 		printFilteredInputs(inputFilters, false)
 	} else {
@@ -10,3 +10,10 @@ type Input interface {
 	// Process processes the input every "interval"
 	Process(Accumulator) error
 }
+
+// Restorer is implemented by inputs which are able to push a backup written
+// by the outputs back into the system they export from.
+type Restorer interface {
+	// Restore recreates the objects of the backup found at path
+	Restore(path string) error
+}
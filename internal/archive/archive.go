@@ -0,0 +1,117 @@
+package archive
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+)
+
+// Entry is a file of a backup run, Name being relative to the run directory
+type Entry struct {
+	Name    string
+	Content []byte
+}
+
+// Possible values of the output_format option. dir writes every object as
+// a file of its own, the other formats are archives of the whole run.
+const (
+	FormatDir    = "dir"
+	FormatZip    = "zip"
+	FormatTarGz  = "tar.gz"
+	FormatTarZst = "tar.zst"
+)
+
+// Format is an output_format with its compression level, a zero level
+// meaning the default level of the compression
+type Format struct {
+	Name  string
+	Level int
+}
+
+// NewFormat returns the format named name, zip when name is empty
+func NewFormat(name string, level int) Format {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = FormatZip
+	}
+	return Format{Name: name, Level: level}
+}
+
+// Validate checks the format name and its compression level
+func (f Format) Validate() error {
+	switch f.Name {
+	case FormatDir:
+		return nil
+	case FormatZip, FormatTarGz:
+		if f.Level < 0 || f.Level > 9 {
+			return fmt.Errorf("compression_level of %s must be between 1 and 9", f.Name)
+		}
+		return nil
+	case FormatTarZst:
+		if f.Level < 0 || f.Level > 19 {
+			return fmt.Errorf("compression_level of %s must be between 1 and 19", f.Name)
+		}
+		_, err := exec.LookPath("zstd")
+		return err
+	}
+	return fmt.Errorf("unknown output_format %q, expected %q, %q, %q or %q",
+		f.Name, FormatZip, FormatTarGz, FormatTarZst, FormatDir)
+}
+
+// IsArchive reports whether runs are written as a single archive
+func (f Format) IsArchive() bool {
+	return f.Name != FormatDir
+}
+
+// Extension returns the extension of the archives, ie, ".tar.gz"
+func (f Format) Extension() string {
+	if !f.IsArchive() {
+		return ""
+	}
+	return "." + f.Name
+}
+
+// Write streams the archive of entries to w, under the run directory dir
+func (f Format) Write(w io.Writer, dir string, entries []Entry) error {
+	switch f.Name {
+	case FormatZip:
+		return writeZip(w, f.Level, dir, entries)
+	case FormatTarGz:
+		return writeTarGz(w, f.Level, dir, entries)
+	case FormatTarZst:
+		return writeTarZst(w, f.Level, dir, entries)
+	}
+	return fmt.Errorf("%s is not an archive format", f.Name)
+}
+
+// writeZip streams a zip of entries to w
+func writeZip(w io.Writer, level int, dir string, entries []Entry) error {
+	archive := zip.NewWriter(w)
+	if level > 0 {
+		archive.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(out, level)
+		})
+	}
+	modified := time.Now()
+	for _, e := range entries {
+		header := &zip.FileHeader{
+			Name:   path.Join(dir, e.Name),
+			Method: zip.Deflate,
+		}
+		header.SetModTime(modified)
+		header.SetMode(0644)
+		writer, err := archive.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if _, err := writer.Write(e.Content); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
@@ -0,0 +1,140 @@
+package archive
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/vikramjakhr/grafana-dashboard-exporter"
+)
+
+// Possible values of the naming option, which decides the file name of
+// every object under its <Type>s directory
+const (
+	// NamingTitle names files after the title without spaces, dashboards
+	// placed in a folder being laid out under a directory named after it
+	NamingTitle = "title"
+	// NamingUID names files after the uid, or the slug of the title for
+	// objects without uid
+	NamingUID = "uid"
+	// NamingSlug names files after the slug of the title
+	NamingSlug = "slug"
+	// NamingFolderSlug lays dashboards out under the slug of their folder
+	// and names files after the slug of the title
+	NamingFolderSlug = "folder/slug"
+	// NamingTitleUID names files after the title followed by the uid
+	NamingTitleUID = "title-uid"
+)
+
+// maxNameLength keeps file names below the 255 bytes most file systems
+// allow, with room for a suffix and the extension
+const maxNameLength = 200
+
+// Naming is a naming strategy, title when empty
+type Naming string
+
+// Validate checks the naming strategy
+func (n Naming) Validate() error {
+	switch n.strategy() {
+	case NamingTitle, NamingUID, NamingSlug, NamingFolderSlug, NamingTitleUID:
+		return nil
+	}
+	return fmt.Errorf("unknown naming %q, expected %q, %q, %q, %q or %q", string(n),
+		NamingTitle, NamingUID, NamingSlug, NamingFolderSlug, NamingTitleUID)
+}
+
+func (n Naming) strategy() string {
+	s := strings.ToLower(strings.TrimSpace(string(n)))
+	if s == "" {
+		return NamingTitle
+	}
+	return s
+}
+
+// Path returns the path of the object of m relative to its run directory,
+// <Type>s/[<folder>/]<name>.json. Every element is sanitized, a title never
+// creates a directory.
+func (n Naming) Path(m gde.Metric) string {
+	dir := fmt.Sprintf("%ss", string(m.Type()))
+	return path.Join(dir, n.base(m)+".json")
+}
+
+// base returns the path of the object under its type directory, without
+// extension
+func (n Naming) base(m gde.Metric) string {
+	inFolder := (m.Type() == gde.TypeDashboard || m.Type() == gde.TypeDashboardPermission) && m.Folder() != ""
+
+	switch n.strategy() {
+	case NamingUID:
+		if m.UID() != "" {
+			return Sanitize(m.UID())
+		}
+		return Slug(m.Title())
+	case NamingSlug:
+		return Slug(m.Title())
+	case NamingFolderSlug:
+		if inFolder {
+			return path.Join(Slug(m.Folder()), Slug(m.Title()))
+		}
+		return Slug(m.Title())
+	case NamingTitleUID:
+		if m.UID() != "" {
+			return Sanitize(m.Title() + "-" + m.UID())
+		}
+		return Sanitize(m.Title())
+	}
+
+	title := Sanitize(strings.Replace(m.Title(), " ", "", -1))
+	if inFolder {
+		return path.Join(Sanitize(strings.Replace(m.Folder(), " ", "", -1)), title)
+	}
+	return title
+}
+
+// Sanitize turns s into a name valid on every common file system. Path
+// separators, characters reserved on windows and control characters are
+// replaced by "_", leading and trailing dots and spaces are removed.
+func Sanitize(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`/\<>:"|?*`, r) {
+			return '_'
+		}
+		return r
+	}, s)
+	s = strings.Trim(truncate(s, maxNameLength), ". ")
+	if s == "" {
+		return "_"
+	}
+	return s
+}
+
+// Slug lowercases s and replaces every run of characters other than letters
+// and digits by a single "-"
+func Slug(s string) string {
+	var b bytes.Buffer
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(r)
+			continue
+		}
+		dash = true
+	}
+	return Sanitize(b.String())
+}
+
+// truncate cuts s to at most n bytes without splitting a rune
+func truncate(s string, n int) string {
+	for len(s) > n {
+		_, size := utf8.DecodeLastRuneInString(s)
+		s = s[:len(s)-size]
+	}
+	return s
+}
@@ -0,0 +1,101 @@
+package archive
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vikramjakhr/grafana-dashboard-exporter"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/metric"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Overview", "Overview"},
+		{"Home/Overview", "Home_Overview"},
+		{`a\b<c>d:e"f|g?h*i`, "a_b_c_d_e_f_g_h_i"},
+		{"tab\there\nline", "tab_here_line"},
+		{"..", "_"},
+		{"../etc", "_etc"},
+		{" .hidden. ", "hidden"},
+		{"", "_"},
+		{"Über Übersicht", "Über Übersicht"},
+	}
+	for _, tt := range tests {
+		if got := Sanitize(tt.in); got != tt.want {
+			t.Errorf("Sanitize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	long := Sanitize(strings.Repeat("é", 150))
+	if len(long) > maxNameLength || !strings.HasPrefix(strings.Repeat("é", 150), long) {
+		t.Errorf("Sanitize of a 300 byte name = %d bytes, want at most %d whole runes", len(long), maxNameLength)
+	}
+}
+
+func TestSlug(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Home Overview", "home-overview"},
+		{"  API -- Latency (p99) ", "api-latency-p99"},
+		{"Home/Overview", "home-overview"},
+		{"Übersicht", "übersicht"},
+		{"!!!", "_"},
+	}
+	for _, tt := range tests {
+		if got := Slug(tt.in); got != tt.want {
+			t.Errorf("Slug(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNamingPath(t *testing.T) {
+	dashboard := metric.New("MainOrg@2019-April-7T10:00:00", gde.TypeDashboard, gde.ActionCreate,
+		"API Latency/p99", "Team A", nil, gde.Metadata{gde.MetaUID: "abc"})
+	general := metric.New("MainOrg@2019-April-7T10:00:00", gde.TypeDashboard, gde.ActionCreate,
+		"Home", "", nil, nil)
+	// folders are not placed in a folder whatever their folder
+	folder := metric.New("MainOrg@2019-April-7T10:00:00", gde.TypeFolder, gde.ActionCreate,
+		"Team A", "Team A", nil, gde.Metadata{gde.MetaUID: "f1"})
+	permission := metric.New("MainOrg@2019-April-7T10:00:00", gde.TypeDashboardPermission, gde.ActionCreate,
+		"API Latency/p99", "Team A", nil, gde.Metadata{gde.MetaUID: "abc"})
+
+	tests := []struct {
+		naming Naming
+		m      gde.Metric
+		want   string
+	}{
+		{"", dashboard, "Dashboards/TeamA/APILatency_p99.json"},
+		{NamingTitle, general, "Dashboards/Home.json"},
+		{NamingTitle, folder, "Folders/TeamA.json"},
+		{NamingTitle, permission, "DashboardPermissions/TeamA/APILatency_p99.json"},
+		{NamingUID, dashboard, "Dashboards/abc.json"},
+		{NamingUID, general, "Dashboards/home.json"},
+		{NamingSlug, dashboard, "Dashboards/api-latency-p99.json"},
+		{NamingFolderSlug, dashboard, "Dashboards/team-a/api-latency-p99.json"},
+		{NamingFolderSlug, folder, "Folders/team-a.json"},
+		{NamingTitleUID, dashboard, "Dashboards/API Latency_p99-abc.json"},
+		{NamingTitleUID, general, "Dashboards/Home.json"},
+		{" Title-UID ", dashboard, "Dashboards/API Latency_p99-abc.json"},
+	}
+	for _, tt := range tests {
+		if got := tt.naming.Path(tt.m); got != tt.want {
+			t.Errorf("Naming(%q).Path(%s) = %q, want %q", tt.naming, tt.m.Title(), got, tt.want)
+		}
+	}
+}
+
+func TestNamingValidate(t *testing.T) {
+	for _, n := range []Naming{"", NamingTitle, NamingUID, NamingSlug, NamingFolderSlug, NamingTitleUID, "UID"} {
+		if err := n.Validate(); err != nil {
+			t.Errorf("Naming(%q).Validate() = %v", n, err)
+		}
+	}
+	if err := Naming("name").Validate(); err == nil {
+		t.Errorf("Naming(%q).Validate() returned no error", "name")
+	}
+}
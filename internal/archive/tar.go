@@ -0,0 +1,80 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// writeTarGz streams a gzip compressed tarball of entries to w
+func writeTarGz(w io.Writer, level int, dir string, entries []Entry) error {
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	gz, err := gzip.NewWriterLevel(w, level)
+	if err != nil {
+		return err
+	}
+	if err := writeTar(gz, dir, entries); err != nil {
+		gz.Close()
+		return err
+	}
+	return gz.Close()
+}
+
+// writeTarZst streams a zstd compressed tarball of entries to w. The
+// tarball is compressed by the zstd binary.
+func writeTarZst(w io.Writer, level int, dir string, entries []Entry) error {
+	args := []string{"--quiet", "--compress", "--stdout"}
+	if level > 0 {
+		args = append(args, "-"+strconv.Itoa(level))
+	}
+	cmd := exec.Command("zstd", args...)
+	cmd.Stdout = w
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	err = writeTar(stdin, dir, entries)
+	stdin.Close()
+	if werr := cmd.Wait(); werr != nil && err == nil {
+		err = fmt.Errorf("zstd: %s %s", werr, strings.TrimSpace(stderr.String()))
+	}
+	return err
+}
+
+// writeTar writes a tarball of entries to w, directories being implied by
+// the names of the files
+func writeTar(w io.Writer, dir string, entries []Entry) error {
+	archive := tar.NewWriter(w)
+	modified := time.Now()
+	for _, e := range entries {
+		header := &tar.Header{
+			Name:     path.Join(dir, e.Name),
+			Mode:     0644,
+			Size:     int64(len(e.Content)),
+			ModTime:  modified,
+			Typeflag: tar.TypeReg,
+		}
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := archive.Write(e.Content); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
@@ -0,0 +1,229 @@
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+)
+
+// Possible values of the method option
+const (
+	MethodNone    = ""
+	MethodAESGCM  = "aes-gcm"
+	MethodAge     = "age"
+	MethodOpenPGP = "openpgp"
+)
+
+// Config is the encryption block of the outputs writing archives. The
+// aes-gcm method derives its key from a passphrase, age and openpgp encrypt
+// to the public keys of the recipients with the age and gpg binaries.
+type Config struct {
+	Method         string   `toml:"method"`
+	Passphrase     string   `toml:"passphrase"`
+	PassphraseFile string   `toml:"passphrase_file"`
+	Recipients     []string `toml:"recipients"`
+}
+
+// Enabled reports whether archives are to be encrypted
+func (c *Config) Enabled() bool {
+	return c.method() != MethodNone
+}
+
+// Validate checks that the settings required by the method are present
+func (c *Config) Validate() error {
+	switch c.method() {
+	case MethodNone:
+		return nil
+	case MethodAESGCM:
+		_, err := c.passphrase()
+		return err
+	case MethodAge, MethodOpenPGP:
+		if len(c.Recipients) == 0 {
+			return fmt.Errorf("encryption method %q requires at least one recipient", c.Method)
+		}
+		_, err := exec.LookPath(program(c.method()))
+		return err
+	}
+	return fmt.Errorf("unknown encryption method %q, expected %q, %q or %q",
+		c.Method, MethodAESGCM, MethodAge, MethodOpenPGP)
+}
+
+// Extension returns the extension appended to the encrypted archives
+func (c *Config) Extension() string {
+	switch c.method() {
+	case MethodAESGCM:
+		return ".enc"
+	case MethodAge:
+		return ".age"
+	case MethodOpenPGP:
+		return ".gpg"
+	}
+	return ""
+}
+
+// Encrypt returns a writer encrypting to w. The writer must be closed to
+// flush the end of the stream, closing it does not close w.
+func (c *Config) Encrypt(w io.Writer) (io.WriteCloser, error) {
+	switch c.method() {
+	case MethodNone:
+		return nopCloser{w}, nil
+	case MethodAESGCM:
+		passphrase, err := c.passphrase()
+		if err != nil {
+			return nil, err
+		}
+		return NewWriter(w, passphrase)
+	case MethodAge:
+		args := []string{"--encrypt"}
+		for _, r := range c.Recipients {
+			args = append(args, "--recipient", r)
+		}
+		return command(w, program(MethodAge), args...)
+	case MethodOpenPGP:
+		args := []string{"--batch", "--yes", "--trust-model", "always", "--encrypt"}
+		for _, r := range c.Recipients {
+			args = append(args, "--recipient", r)
+		}
+		return command(w, program(MethodOpenPGP), args...)
+	}
+	return nil, fmt.Errorf("unknown encryption method %q", c.Method)
+}
+
+func (c *Config) method() string {
+	return strings.ToLower(strings.TrimSpace(c.Method))
+}
+
+// passphrase returns the passphrase option, or the content of the
+// passphrase_file
+func (c *Config) passphrase() (string, error) {
+	if c.Passphrase != "" {
+		return c.Passphrase, nil
+	}
+	if c.PassphraseFile != "" {
+		return ReadPassphrase(c.PassphraseFile)
+	}
+	return "", errors.New("passphrase or passphrase_file is required by the aes-gcm encryption")
+}
+
+// ReadPassphrase reads a passphrase from the first line of a file
+func ReadPassphrase(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	passphrase := strings.TrimSpace(strings.SplitN(string(data), "\n", 2)[0])
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase file %s is empty", path)
+	}
+	return passphrase, nil
+}
+
+// DecryptOptions are the secrets needed to decrypt an archive. Archives
+// encrypted with openpgp are decrypted by gpg with the keys of its keyring.
+type DecryptOptions struct {
+	// Passphrase of the aes-gcm method
+	Passphrase string
+	// Identity is the age identity file
+	Identity string
+}
+
+// Decrypt detects the method an archive was encrypted with and writes its
+// plaintext to w
+func Decrypt(r io.Reader, w io.Writer, opts DecryptOptions) error {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(len(ageHeader))
+
+	switch {
+	case bytes.HasPrefix(head, []byte(magic)):
+		if opts.Passphrase == "" {
+			return errors.New("a passphrase is required to decrypt an aes-gcm archive")
+		}
+		dr, err := NewReader(br, opts.Passphrase)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, dr)
+		return err
+	case bytes.HasPrefix(head, []byte(ageHeader)), bytes.HasPrefix(head, []byte(ageArmorHeader)):
+		if opts.Identity == "" {
+			return errors.New("an identity file is required to decrypt an age archive")
+		}
+		return run(br, w, program(MethodAge), "--decrypt", "--identity", opts.Identity)
+	}
+	// anything else is left to gpg, which reports what it does not know
+	return run(br, w, program(MethodOpenPGP), "--batch", "--decrypt")
+}
+
+const (
+	ageHeader      = "age-encryption.org/"
+	ageArmorHeader = "-----BEGIN AGE"
+)
+
+// program returns the binary the method runs
+func program(method string) string {
+	if method == MethodOpenPGP {
+		return "gpg"
+	}
+	return method
+}
+
+// command starts name with args, its standard output being written to w,
+// and returns its standard input
+func command(w io.Writer, name string, args ...string) (io.WriteCloser, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = w
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &cmdWriter{WriteCloser: stdin, cmd: cmd, stderr: stderr}, nil
+}
+
+// run runs name with args, streaming r through it to w
+func run(r io.Reader, w io.Writer, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = r
+	cmd.Stdout = w
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %s %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// cmdWriter is the standard input of a running command, closing it waits
+// for the command to exit
+type cmdWriter struct {
+	io.WriteCloser
+	cmd    *exec.Cmd
+	stderr *bytes.Buffer
+}
+
+func (c *cmdWriter) Close() error {
+	if err := c.WriteCloser.Close(); err != nil {
+		return err
+	}
+	if err := c.cmd.Wait(); err != nil {
+		return fmt.Errorf("%s: %s %s", c.cmd.Path, err, strings.TrimSpace(c.stderr.String()))
+	}
+	return nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
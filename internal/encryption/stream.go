@@ -0,0 +1,197 @@
+package encryption
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/vikramjakhr/grafana-dashboard-exporter/internal/secret"
+)
+
+// The aes-gcm stream starts with the magic, the salt of the key derivation
+// and the nonce prefix. The plaintext follows in chunks of chunkSize bytes,
+// each one sealed on its own and preceded by a flag byte set on the last
+// chunk only. The flag is authenticated and the chunk counter is part of
+// the nonce, so that truncated or reordered streams fail to decrypt.
+const (
+	magic     = "GDEENC1\n"
+	saltSize  = 16
+	chunkSize = 64 * 1024
+
+	flagMore = 0
+	flagLast = 1
+)
+
+var ErrDecrypt = secret.ErrDecrypt
+
+// writer seals the plaintext written to it chunk by chunk
+type writer struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	nonce   []byte
+	counter uint64
+	buf     []byte
+	closed  bool
+}
+
+// NewWriter returns a writer encrypting to w with AES-256-GCM under a key
+// derived from the passphrase
+func NewWriter(w io.Writer, passphrase string) (io.WriteCloser, error) {
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	aead, err := secret.NewGCM(secret.DeriveKey(passphrase, salt))
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	header := append([]byte(magic), salt...)
+	if _, err := w.Write(append(header, nonce...)); err != nil {
+		return nil, err
+	}
+	return &writer{
+		w:     w,
+		aead:  aead,
+		nonce: nonce,
+		buf:   make([]byte, 0, chunkSize),
+	}, nil
+}
+
+func (e *writer) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New("write to a closed encryption writer")
+	}
+	n := 0
+	for len(p) > 0 {
+		// a full chunk is only sealed once more data comes, it may be the
+		// last one otherwise
+		if len(e.buf) == chunkSize {
+			if err := e.seal(flagMore); err != nil {
+				return n, err
+			}
+		}
+		c := copy(e.buf[len(e.buf):chunkSize], p)
+		e.buf = e.buf[:len(e.buf)+c]
+		p = p[c:]
+		n += c
+	}
+	return n, nil
+}
+
+// Close seals the last chunk, it does not close the underlying writer
+func (e *writer) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.seal(flagLast)
+}
+
+func (e *writer) seal(flag byte) error {
+	out := make([]byte, 1, 1+len(e.buf)+e.aead.Overhead())
+	out[0] = flag
+	out = e.aead.Seal(out, chunkNonce(e.nonce, e.counter), e.buf, out[:1])
+	e.counter++
+	e.buf = e.buf[:0]
+	_, err := e.w.Write(out)
+	return err
+}
+
+// reader opens the chunks sealed by writer
+type reader struct {
+	r       io.Reader
+	aead    cipher.AEAD
+	nonce   []byte
+	counter uint64
+	chunk   []byte
+	buf     []byte
+	last    bool
+}
+
+// NewReader returns a reader decrypting the stream written by NewWriter
+func NewReader(r io.Reader, passphrase string) (io.Reader, error) {
+	header := make([]byte, len(magic)+saltSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, ErrDecrypt
+	}
+	if string(header[:len(magic)]) != magic {
+		return nil, errors.New("not an aes-gcm encrypted archive")
+	}
+	aead, err := secret.NewGCM(secret.DeriveKey(passphrase, header[len(magic):]))
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(r, nonce); err != nil {
+		return nil, ErrDecrypt
+	}
+	return &reader{
+		r:     r,
+		aead:  aead,
+		nonce: nonce,
+		chunk: make([]byte, 1+chunkSize+aead.Overhead()),
+	}, nil
+}
+
+func (d *reader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.last {
+			return 0, io.EOF
+		}
+		if err := d.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+func (d *reader) open() error {
+	n, err := io.ReadFull(d.r, d.chunk)
+	if err == io.EOF {
+		// the stream was truncated before its last chunk
+		return ErrDecrypt
+	}
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	chunk := d.chunk[:n]
+	switch {
+	case chunk[0] == flagLast:
+		// nothing may follow the last chunk
+		if m, _ := d.r.Read(make([]byte, 1)); m > 0 {
+			return ErrDecrypt
+		}
+		d.last = true
+	case chunk[0] != flagMore || n < len(d.chunk):
+		return ErrDecrypt
+	}
+
+	plaintext, err := d.aead.Open(chunk[1:1], chunkNonce(d.nonce, d.counter), chunk[1:], chunk[:1])
+	if err != nil {
+		return ErrDecrypt
+	}
+	d.counter++
+	d.buf = plaintext
+	return nil
+}
+
+// chunkNonce xors the chunk counter into the last bytes of the nonce
+func chunkNonce(nonce []byte, counter uint64) []byte {
+	n := make([]byte, len(nonce))
+	copy(n, nonce)
+	var c [8]byte
+	binary.BigEndian.PutUint64(c[:], counter)
+	for i := range c {
+		n[len(n)-8+i] ^= c[i]
+	}
+	return n
+}
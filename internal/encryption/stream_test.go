@@ -0,0 +1,137 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// seal encrypts plaintext with passphrase, writing it in writes of size
+// bytes
+func seal(t *testing.T, passphrase string, plaintext []byte, size int) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p := plaintext; len(p) > 0; {
+		n := size
+		if n > len(p) {
+			n = len(p)
+		}
+		if _, err := w.Write(p[:n]); err != nil {
+			t.Fatal(err)
+		}
+		p = p[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func open(passphrase string, sealed []byte) ([]byte, error) {
+	r, err := NewReader(bytes.NewReader(sealed), passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
+func random(t *testing.T, n int) []byte {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		size  int
+		write int
+	}{
+		{"empty", 0, 1},
+		{"single byte", 1, 1},
+		{"less than a chunk", chunkSize - 1, 1000},
+		{"exactly a chunk", chunkSize, chunkSize},
+		{"a chunk and a byte", chunkSize + 1, 7},
+		{"exactly several chunks", 3 * chunkSize, 4096},
+		{"several chunks in a single write", 3*chunkSize + 123, 4 * chunkSize},
+	}
+	for _, tt := range tests {
+		plaintext := random(t, tt.size)
+		sealed := seal(t, "secret", plaintext, tt.write)
+		got, err := open("secret", sealed)
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("%s: decrypted %d bytes differ from the %d encrypted", tt.name, len(got), len(plaintext))
+		}
+	}
+}
+
+func TestStreamWrongKey(t *testing.T) {
+	sealed := seal(t, "secret", random(t, 2*chunkSize), 4096)
+	if _, err := open("not the secret", sealed); err != ErrDecrypt {
+		t.Errorf("decrypting with a wrong passphrase returned %v, want %v", err, ErrDecrypt)
+	}
+}
+
+func TestStreamTruncated(t *testing.T) {
+	sealed := seal(t, "secret", random(t, 2*chunkSize+10), 4096)
+	header := len(magic) + saltSize + 12
+	chunk := 1 + chunkSize + 16
+
+	tests := []struct {
+		name string
+		size int
+	}{
+		{"in the header", header - 1},
+		{"after the header", header},
+		{"in the first chunk", header + 100},
+		{"after the first chunk", header + chunk},
+		{"after every full chunk", header + 2*chunk},
+		{"in the last chunk", len(sealed) - 1},
+	}
+	for _, tt := range tests {
+		if _, err := open("secret", sealed[:tt.size]); err == nil {
+			t.Errorf("truncated %s: decrypted without error", tt.name)
+		}
+	}
+
+	if _, err := open("secret", append(sealed, 0)); err == nil {
+		t.Errorf("trailing data: decrypted without error")
+	}
+}
+
+func TestStreamTampered(t *testing.T) {
+	sealed := seal(t, "secret", random(t, chunkSize+10), 4096)
+	header := len(magic) + saltSize + 12
+	chunk := 1 + chunkSize + 16
+
+	// the flag of the first chunk is authenticated, claiming it is the
+	// last one must not drop the chunks following it
+	flag := append([]byte{}, sealed...)
+	flag[header] = flagLast
+	if _, err := open("secret", flag[:header+chunk]); err == nil {
+		t.Errorf("first chunk flagged last: decrypted without error")
+	}
+
+	body := append([]byte{}, sealed...)
+	body[header+10] ^= 1
+	if _, err := open("secret", body); err != ErrDecrypt {
+		t.Errorf("tampered chunk returned %v, want %v", err, ErrDecrypt)
+	}
+}
+
+func TestStreamNotEncrypted(t *testing.T) {
+	if _, err := open("secret", []byte("PK\x03\x04 not an encrypted archive")); err == nil {
+		t.Errorf("decrypting a plain archive returned no error")
+	}
+}
@@ -45,3 +45,19 @@ func (d *Duration) UnmarshalTOML(b []byte) error {
 
 	return nil
 }
+
+// RunTimeLayout is the layout of the timestamp in the <Org>@<timestamp>
+// directory every run is written to
+const RunTimeLayout = "2006-January-2T15:04:05"
+
+var version = "unknown"
+
+// SetVersion sets the version of gde, reported in the manifest of the runs
+func SetVersion(v string) {
+	version = v
+}
+
+// Version returns the version of gde
+func Version() string {
+	return version
+}
@@ -0,0 +1,127 @@
+package retention
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vikramjakhr/grafana-dashboard-exporter/internal"
+)
+
+// Policy decides which backup runs are kept. A run is kept as soon as one
+// of the configured rules keeps it, a policy without any rule keeps every
+// run. Rules are applied to the runs of each org separately.
+type Policy struct {
+	// KeepLast keeps the n most recent runs
+	KeepLast int `toml:"keep_last"`
+	// MaxAge keeps the runs younger than the duration
+	MaxAge internal.Duration `toml:"max_age"`
+	// KeepDaily, KeepWeekly and KeepMonthly keep the most recent run of
+	// the n most recent days, weeks and months which have a run
+	KeepDaily   int `toml:"keep_daily"`
+	KeepWeekly  int `toml:"keep_weekly"`
+	KeepMonthly int `toml:"keep_monthly"`
+
+	// DryRun only logs the runs which would be removed
+	DryRun bool `toml:"dry_run"`
+}
+
+// Run is a single backup run, found by the name it was written with
+type Run struct {
+	// Name is the name of the run directory or archive, ie,
+	// "MainOrg@2019-April-7T10:00:00.zip"
+	Name string
+	Org  string
+	Time time.Time
+}
+
+// ParseRun parses a <Org>@<timestamp> run name, with or without archive
+// extension. ok is false for names which are not a run.
+func ParseRun(name string) (run Run, ok bool) {
+	i := strings.LastIndex(name, "@")
+	if i <= 0 {
+		return run, false
+	}
+	// the timestamp does not contain any dot, what follows is the
+	// extension of the archive
+	ts := strings.SplitN(name[i+1:], ".", 2)[0]
+	t, err := time.ParseInLocation(internal.RunTimeLayout, ts, time.Local)
+	if err != nil {
+		return run, false
+	}
+	return Run{Name: name, Org: name[:i], Time: t}, true
+}
+
+// Enabled reports whether the policy has at least one rule
+func (p *Policy) Enabled() bool {
+	return p.KeepLast > 0 || p.MaxAge.Duration > 0 ||
+		p.KeepDaily > 0 || p.KeepWeekly > 0 || p.KeepMonthly > 0
+}
+
+// Expired returns the runs which are not kept by the policy at now
+func (p *Policy) Expired(runs []Run, now time.Time) []Run {
+	if !p.Enabled() {
+		return nil
+	}
+
+	orgs := make(map[string][]Run)
+	for _, r := range runs {
+		orgs[r.Org] = append(orgs[r.Org], r)
+	}
+
+	var expired []Run
+	for _, orgRuns := range orgs {
+		// most recent first
+		sort.Slice(orgRuns, func(i, j int) bool {
+			return orgRuns[i].Time.After(orgRuns[j].Time)
+		})
+
+		keep := make([]bool, len(orgRuns))
+		for i, r := range orgRuns {
+			if i < p.KeepLast {
+				keep[i] = true
+			}
+			if p.MaxAge.Duration > 0 && now.Sub(r.Time) < p.MaxAge.Duration {
+				keep[i] = true
+			}
+		}
+		keepBuckets(orgRuns, keep, p.KeepDaily, func(t time.Time) string {
+			return t.Format("2006-01-02")
+		})
+		keepBuckets(orgRuns, keep, p.KeepWeekly, func(t time.Time) string {
+			y, w := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", y, w)
+		})
+		keepBuckets(orgRuns, keep, p.KeepMonthly, func(t time.Time) string {
+			return t.Format("2006-01")
+		})
+
+		for i, r := range orgRuns {
+			if !keep[i] {
+				expired = append(expired, r)
+			}
+		}
+	}
+
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].Time.Before(expired[j].Time)
+	})
+	return expired
+}
+
+// keepBuckets keeps the most recent run of the n most recent buckets, runs
+// must be sorted most recent first
+func keepBuckets(runs []Run, keep []bool, n int, bucket func(time.Time) string) {
+	seen := make(map[string]bool)
+	for i, r := range runs {
+		if len(seen) >= n {
+			return
+		}
+		b := bucket(r.Time)
+		if !seen[b] {
+			seen[b] = true
+			keep[i] = true
+		}
+	}
+}
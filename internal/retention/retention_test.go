@@ -0,0 +1,198 @@
+package retention
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vikramjakhr/grafana-dashboard-exporter/internal"
+)
+
+// run returns the run of org at t, named as the file output names its zip
+func run(org string, t time.Time) Run {
+	return Run{Name: org + "@" + t.Format(internal.RunTimeLayout) + ".zip", Org: org, Time: t}
+}
+
+func TestParseRun(t *testing.T) {
+	at := time.Date(2019, time.April, 7, 10, 0, 0, 0, time.Local)
+	tests := []struct {
+		name string
+		org  string
+		ok   bool
+	}{
+		{"MainOrg@2019-April-7T10:00:00", "MainOrg", true},
+		{"MainOrg@2019-April-7T10:00:00.zip", "MainOrg", true},
+		{"MainOrg@2019-April-7T10:00:00.tar.gz", "MainOrg", true},
+		{"MainOrg@2019-April-7T10:00:00.tar.zst", "MainOrg", true},
+		{"MainOrg@2019-April-7T10:00:00.zip.enc", "MainOrg", true},
+		{"MainOrg@2019-April-7T10:00:00.tar.gz.age", "MainOrg", true},
+		{"MainOrg@2019-April-7T10:00:00.tar.zst.gpg", "MainOrg", true},
+		{"MainOrg.@2019-April-7T10:00:00.zip", "MainOrg.", true},
+		{"Team@Ops@2019-April-7T10:00:00.zip", "Team@Ops", true},
+		{"@2019-April-7T10:00:00.zip", "", false},
+		{"MainOrg.zip", "", false},
+		{"MainOrg@yesterday.zip", "", false},
+		{"MainOrg@2019-04-07T10:00:00.zip", "", false},
+	}
+	for _, tt := range tests {
+		r, ok := ParseRun(tt.name)
+		if ok != tt.ok {
+			t.Errorf("ParseRun(%q) ok = %v, want %v", tt.name, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if r.Name != tt.name || r.Org != tt.org || !r.Time.Equal(at) {
+			t.Errorf("ParseRun(%q) = %+v, want org %q at %s", tt.name, r, tt.org, at)
+		}
+	}
+}
+
+func TestExpired(t *testing.T) {
+	now := time.Date(2019, time.April, 30, 12, 0, 0, 0, time.Local)
+	day := 24 * time.Hour
+
+	// two runs a day over the last 60 days, for two orgs
+	var runs []Run
+	for _, org := range []string{"MainOrg", "Other"} {
+		for d := 0; d < 60; d++ {
+			morning := time.Date(2019, time.April, 30-d, 6, 0, 0, 0, time.Local)
+			runs = append(runs, run(org, morning), run(org, morning.Add(6*time.Hour)))
+		}
+	}
+
+	tests := []struct {
+		name   string
+		policy Policy
+		// kept lists the runs kept for each org, the others expire
+		kept []time.Time
+	}{
+		{
+			name:   "no rule keeps everything",
+			policy: Policy{},
+			kept:   nil,
+		},
+		{
+			name:   "keep_last",
+			policy: Policy{KeepLast: 3},
+			kept: []time.Time{
+				time.Date(2019, time.April, 30, 12, 0, 0, 0, time.Local),
+				time.Date(2019, time.April, 30, 6, 0, 0, 0, time.Local),
+				time.Date(2019, time.April, 29, 12, 0, 0, 0, time.Local),
+			},
+		},
+		{
+			name:   "max_age",
+			policy: Policy{MaxAge: internal.Duration{Duration: day + time.Hour}},
+			kept: []time.Time{
+				time.Date(2019, time.April, 30, 12, 0, 0, 0, time.Local),
+				time.Date(2019, time.April, 30, 6, 0, 0, 0, time.Local),
+				time.Date(2019, time.April, 29, 12, 0, 0, 0, time.Local),
+			},
+		},
+		{
+			name:   "keep_daily keeps the last run of each day",
+			policy: Policy{KeepDaily: 2},
+			kept: []time.Time{
+				time.Date(2019, time.April, 30, 12, 0, 0, 0, time.Local),
+				time.Date(2019, time.April, 29, 12, 0, 0, 0, time.Local),
+			},
+		},
+		{
+			// April 30 2019 is a Tuesday, ISO weeks start on Monday
+			name:   "keep_weekly keeps the last run of each iso week",
+			policy: Policy{KeepWeekly: 3},
+			kept: []time.Time{
+				time.Date(2019, time.April, 30, 12, 0, 0, 0, time.Local),
+				time.Date(2019, time.April, 28, 12, 0, 0, 0, time.Local),
+				time.Date(2019, time.April, 21, 12, 0, 0, 0, time.Local),
+			},
+		},
+		{
+			name:   "keep_monthly keeps the last run of each month",
+			policy: Policy{KeepMonthly: 3},
+			kept: []time.Time{
+				time.Date(2019, time.April, 30, 12, 0, 0, 0, time.Local),
+				time.Date(2019, time.March, 31, 12, 0, 0, 0, time.Local),
+			},
+		},
+		{
+			name:   "rules add up",
+			policy: Policy{KeepLast: 1, KeepDaily: 2, KeepMonthly: 2},
+			kept: []time.Time{
+				time.Date(2019, time.April, 30, 12, 0, 0, 0, time.Local),
+				time.Date(2019, time.April, 29, 12, 0, 0, 0, time.Local),
+				time.Date(2019, time.March, 31, 12, 0, 0, 0, time.Local),
+			},
+		},
+	}
+	for _, tt := range tests {
+		expired := tt.policy.Expired(runs, now)
+		if !tt.policy.Enabled() {
+			if len(expired) != 0 {
+				t.Errorf("%s: %d runs expired, want none", tt.name, len(expired))
+			}
+			continue
+		}
+
+		kept := make(map[string]bool)
+		for _, k := range tt.kept {
+			kept[k.String()] = true
+		}
+		if want := len(runs) - 2*len(tt.kept); len(expired) != want {
+			t.Errorf("%s: %d runs expired, want %d", tt.name, len(expired), want)
+		}
+		for i, r := range expired {
+			if kept[r.Time.String()] {
+				t.Errorf("%s: run %s expired, want it kept", tt.name, r.Name)
+			}
+			if i > 0 && r.Time.Before(expired[i-1].Time) {
+				t.Errorf("%s: expired runs are not sorted oldest first", tt.name)
+			}
+		}
+	}
+}
+
+func TestExpiredPerOrg(t *testing.T) {
+	now := time.Date(2019, time.April, 30, 12, 0, 0, 0, time.Local)
+	runs := []Run{
+		run("MainOrg", now.Add(-3*time.Hour)),
+		run("MainOrg", now.Add(-2*time.Hour)),
+		run("MainOrg", now.Add(-1*time.Hour)),
+		// a single old run of another org is the last one of that org
+		run("Other", now.Add(-48*time.Hour)),
+	}
+	policy := Policy{KeepLast: 2}
+	want := []Run{runs[0]}
+	if got := policy.Expired(runs, now); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expired() = %+v, want %+v", got, want)
+	}
+}
+
+func TestKeepBuckets(t *testing.T) {
+	at := func(day, hour int) Run {
+		return run("MainOrg", time.Date(2019, time.April, day, hour, 0, 0, 0, time.Local))
+	}
+	byDay := func(t time.Time) string { return t.Format("2006-01-02") }
+	// most recent first, as Expired sorts them
+	runs := []Run{at(30, 18), at(30, 6), at(28, 12), at(27, 18), at(27, 6)}
+
+	tests := []struct {
+		n    int
+		want []bool
+	}{
+		{0, []bool{false, false, false, false, false}},
+		{1, []bool{true, false, false, false, false}},
+		{2, []bool{true, false, true, false, false}},
+		{3, []bool{true, false, true, true, false}},
+		{10, []bool{true, false, true, true, false}},
+	}
+	for _, tt := range tests {
+		keep := make([]bool, len(runs))
+		keepBuckets(runs, keep, tt.n, byDay)
+		if !reflect.DeepEqual(keep, tt.want) {
+			t.Errorf("keepBuckets(%d) = %v, want %v", tt.n, keep, tt.want)
+		}
+	}
+}
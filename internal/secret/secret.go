@@ -0,0 +1,171 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"io"
+	"strings"
+)
+
+const (
+	// prefix marks the strings encrypted by EncryptString
+	prefix = "gde:enc:v1:"
+
+	saltSize = 16
+
+	// iterations of the PBKDF2 key derivation
+	iterations = 100000
+)
+
+var ErrDecrypt = errors.New("unable to decrypt, wrong key or corrupted data")
+
+// DeriveKey derives a 256 bit key from the passphrase and salt with
+// PBKDF2-HMAC-SHA256
+func DeriveKey(passphrase string, salt []byte) []byte {
+	return pbkdf2([]byte(passphrase), salt, iterations, 32, sha256.New)
+}
+
+// Encrypt seals plaintext with AES-256-GCM under a key derived from the
+// passphrase. The random salt and nonce are prepended to the ciphertext.
+func Encrypt(passphrase string, plaintext []byte) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	aead, err := NewGCM(DeriveKey(passphrase, salt))
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return seal(aead, salt, nonce, plaintext), nil
+}
+
+// EncryptDeterministic seals plaintext like Encrypt, the salt and nonce
+// being derived from the passphrase and plaintext with HMAC-SHA256 instead
+// of drawn at random. The same plaintext always gives the same ciphertext,
+// which tells equal plaintexts apart from different ones but keeps
+// exports of unchanged secrets unchanged. Decrypt opens it.
+func EncryptDeterministic(passphrase string, plaintext []byte) ([]byte, error) {
+	salt := derive(passphrase, "salt", plaintext)[:saltSize]
+	aead, err := NewGCM(DeriveKey(passphrase, salt))
+	if err != nil {
+		return nil, err
+	}
+	nonce := derive(passphrase, "nonce", plaintext)[:aead.NonceSize()]
+	return seal(aead, salt, nonce, plaintext), nil
+}
+
+// seal returns the salt and nonce followed by the ciphertext of plaintext
+func seal(aead cipher.AEAD, salt, nonce, plaintext []byte) []byte {
+	sealed := append(append([]byte{}, salt...), nonce...)
+	return aead.Seal(sealed, nonce, plaintext, nil)
+}
+
+// derive returns the HMAC-SHA256 of the purpose and plaintext keyed by the
+// passphrase
+func derive(passphrase, purpose string, plaintext []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(passphrase))
+	mac.Write([]byte(purpose))
+	mac.Write([]byte{0})
+	mac.Write(plaintext)
+	return mac.Sum(nil)
+}
+
+// Decrypt opens data sealed by Encrypt
+func Decrypt(passphrase string, sealed []byte) ([]byte, error) {
+	if len(sealed) < saltSize {
+		return nil, ErrDecrypt
+	}
+	aead, err := NewGCM(DeriveKey(passphrase, sealed[:saltSize]))
+	if err != nil {
+		return nil, err
+	}
+	sealed = sealed[saltSize:]
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// EncryptString encrypts s into a printable "gde:enc:v1:<base64>" string.
+// It is deterministic, see EncryptDeterministic, so that exports of the
+// same secret do not change from one run to the next.
+func EncryptString(passphrase, s string) (string, error) {
+	sealed, err := EncryptDeterministic(passphrase, []byte(s))
+	if err != nil {
+		return "", err
+	}
+	return prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptString decrypts a string encrypted by EncryptString
+func DecryptString(passphrase, s string) (string, error) {
+	if !IsEncrypted(s) {
+		return "", errors.New("not an encrypted string")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, prefix))
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := Decrypt(passphrase, sealed)
+	return string(plaintext), err
+}
+
+// IsEncrypted reports whether s was encrypted by EncryptString
+func IsEncrypted(s string) bool {
+	return strings.HasPrefix(s, prefix)
+}
+
+// NewGCM returns AES-GCM for the given 128, 192 or 256 bit key
+func NewGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2 implements the key derivation of RFC 8018, section 5.2
+func pbkdf2(password, salt []byte, iter, keyLen int, h func() hash.Hash) []byte {
+	prf := hmac.New(h, password)
+	hashLen := prf.Size()
+	numBlocks := (keyLen + hashLen - 1) / hashLen
+
+	var buf [4]byte
+	dk := make([]byte, 0, numBlocks*hashLen)
+	u := make([]byte, hashLen)
+	for block := 1; block <= numBlocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf[:], uint32(block))
+		prf.Write(buf[:4])
+		dk = prf.Sum(dk)
+		t := dk[len(dk)-hashLen:]
+		copy(u, t)
+
+		for n := 2; n <= iter; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = u[:0]
+			u = prf.Sum(u)
+			for x := range u {
+				t[x] ^= u[x]
+			}
+		}
+	}
+	return dk[:keyLen]
+}
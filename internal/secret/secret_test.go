@@ -0,0 +1,87 @@
+package secret
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"testing"
+)
+
+func TestPBKDF2(t *testing.T) {
+	tests := []struct {
+		name       string
+		h          func() hash.Hash
+		password   string
+		salt       string
+		iterations int
+		keyLen     int
+		want       string
+	}{
+		// RFC 6070
+		{"sha1", sha1.New, "password", "salt", 1, 20, "0c60c80f961f0e71f3a9b524af6012062fe037a6"},
+		{"sha1", sha1.New, "password", "salt", 2, 20, "ea6c014dc72d6f8ccd1ed92ace1d41f0d8de8957"},
+		{"sha1", sha1.New, "password", "salt", 4096, 20, "4b007901b765489abead49d926f721d065a429c1"},
+		{"sha1", sha1.New, "passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, 25,
+			"3d2eec4fe41c849b80c8d83662c0e44a8b291a964cf2f07038"},
+		{"sha1", sha1.New, "pass\x00word", "sa\x00lt", 4096, 16, "56fa6aa75548099dcc37d7f03425e0c3"},
+		// PBKDF2-HMAC-SHA256, as used by DeriveKey
+		{"sha256", sha256.New, "password", "salt", 1, 32,
+			"120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+		{"sha256", sha256.New, "password", "salt", 2, 32,
+			"ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"},
+		{"sha256", sha256.New, "password", "salt", 4096, 32,
+			"c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+	}
+	for _, tt := range tests {
+		got := hex.EncodeToString(pbkdf2([]byte(tt.password), []byte(tt.salt), tt.iterations, tt.keyLen, tt.h))
+		if got != tt.want {
+			t.Errorf("pbkdf2-%s(%q, %q, %d) = %s, want %s", tt.name, tt.password, tt.salt, tt.iterations, got, tt.want)
+		}
+	}
+}
+
+func TestEncryptString(t *testing.T) {
+	for _, s := range []string{"", "p@ss:word", "a longer secret spanning more than a single aes block"} {
+		encrypted, err := EncryptString("key", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !IsEncrypted(encrypted) {
+			t.Errorf("EncryptString(%q) = %q, not recognized as encrypted", s, encrypted)
+		}
+		again, err := EncryptString("key", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again != encrypted {
+			t.Errorf("EncryptString(%q) is not deterministic, %q then %q", s, encrypted, again)
+		}
+		if other, _ := EncryptString("other key", s); other == encrypted {
+			t.Errorf("EncryptString(%q) gives the same value under another key", s)
+		}
+
+		decrypted, err := DecryptString("key", encrypted)
+		if err != nil || decrypted != s {
+			t.Errorf("DecryptString(EncryptString(%q)) = %q, %v", s, decrypted, err)
+		}
+		if _, err := DecryptString("wrong key", encrypted); err != ErrDecrypt {
+			t.Errorf("DecryptString with a wrong key returned %v, want %v", err, ErrDecrypt)
+		}
+	}
+}
+
+func TestDecryptRandom(t *testing.T) {
+	// values sealed with a random salt and nonce open the same way
+	sealed, err := Encrypt("key", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := Decrypt("key", sealed)
+	if err != nil || string(plaintext) != "secret" {
+		t.Errorf("Decrypt(Encrypt(%q)) = %q, %v", "secret", plaintext, err)
+	}
+	if _, err := Decrypt("key", sealed[:saltSize+4]); err != ErrDecrypt {
+		t.Errorf("Decrypt of a truncated value returned %v, want %v", err, ErrDecrypt)
+	}
+}
@@ -0,0 +1,64 @@
+package tls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// ClientConfig holds the TLS options of a client. Plugins connecting to a
+// TLS server expose them as tls_ca, tls_cert, tls_key, insecure_skip_verify
+// and server_name.
+type ClientConfig struct {
+	// TLSCA is a PEM file of the certificate authorities the server
+	// certificate is verified with, the system pool when empty
+	TLSCA string
+	// TLSCert and TLSKey are the PEM files of the client certificate and
+	// its key, for servers requiring mutual TLS
+	TLSCert string
+	TLSKey  string
+	// InsecureSkipVerify skips the verification of the server certificate
+	InsecureSkipVerify bool
+	// ServerName is the name the server certificate is verified against,
+	// the host of the url when empty
+	ServerName string
+}
+
+// TLSConfig returns the tls config of the options, nil when none is set so
+// that the defaults of the client apply
+func (c *ClientConfig) TLSConfig() (*tls.Config, error) {
+	if c.TLSCA == "" && c.TLSCert == "" && c.TLSKey == "" && !c.InsecureSkipVerify && c.ServerName == "" {
+		return nil, nil
+	}
+
+	config := &tls.Config{
+		InsecureSkipVerify: c.InsecureSkipVerify,
+		ServerName:         c.ServerName,
+	}
+
+	if c.TLSCA != "" {
+		pem, err := ioutil.ReadFile(c.TLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("could not read tls_ca %s: %s", c.TLSCA, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in tls_ca %s", c.TLSCA)
+		}
+		config.RootCAs = pool
+	}
+
+	if c.TLSCert != "" || c.TLSKey != "" {
+		if c.TLSCert == "" || c.TLSKey == "" {
+			return nil, errors.New("tls_cert and tls_key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(c.TLSCert, c.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate %s: %s", c.TLSCert, err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
@@ -0,0 +1,169 @@
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/vikramjakhr/grafana-dashboard-exporter"
+)
+
+// Name is the name of the manifest file, at the root of the run directory
+const Name = "manifest.json"
+
+// Manifest describes a backup run and lists every object it holds, so that
+// a backup can be verified without the grafana instance it was made of
+type Manifest struct {
+	// Dir is the run directory, ie, "MainOrg@2019-April-7T10:00:00"
+	Dir        string    `json:"dir"`
+	GDEVersion string    `json:"gde_version"`
+	Grafana    Grafana   `json:"grafana"`
+	Started    time.Time `json:"started"`
+	Finished   time.Time `json:"finished"`
+	// Dashboards counts the dashboards the input came across, when it
+	// reports them
+	Dashboards *Counts `json:"dashboards,omitempty"`
+	// Failed is the number of errors the input reported along the run,
+	// objects or parts of objects missing from it
+	Failed int `json:"failed,omitempty"`
+	// Count is the number of objects of the run, CountByType the number of
+	// objects of every type, as emitted by the input. Objects missing from
+	// the list were lost on their way to the output.
+	Count       int                   `json:"count"`
+	CountByType map[gde.ValueType]int `json:"count_by_type,omitempty"`
+
+	Objects []Object `json:"objects"`
+}
+
+// Counts tells how many of the objects discovered by the input made it to
+// the run, the others being filtered out or failing to export
+type Counts struct {
+	Discovered int `json:"discovered"`
+	Filtered   int `json:"filtered"`
+	Failed     int `json:"failed"`
+	Exported   int `json:"exported"`
+}
+
+// Grafana is the instance a run was exported from
+type Grafana struct {
+	Host    string `json:"host"`
+	Version string `json:"version,omitempty"`
+	Commit  string `json:"commit,omitempty"`
+}
+
+// Object is a single exported object of a run
+type Object struct {
+	Type    gde.ValueType `json:"type"`
+	UID     string        `json:"uid,omitempty"`
+	Title   string        `json:"title"`
+	Folder  string        `json:"folder,omitempty"`
+	Version int64         `json:"version,omitempty"`
+	// Path is the path of the file relative to the run directory
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Size   int    `json:"size"`
+}
+
+// NewObject describes the object of metric written at path
+func NewObject(metric gde.Metric, path string) Object {
+	sum := sha256.Sum256(metric.Content())
+	uid, version := identity(metric.Content())
+	if metric.UID() != "" {
+		uid = metric.UID()
+	}
+	if metric.Version() != 0 {
+		version = metric.Version()
+	}
+	return Object{
+		Type:    metric.Type(),
+		UID:     uid,
+		Title:   metric.Title(),
+		Folder:  metric.Folder(),
+		Version: version,
+		Path:    path,
+		SHA256:  hex.EncodeToString(sum[:]),
+		Size:    len(metric.Content()),
+	}
+}
+
+// identity returns the uid and version of an exported object, dashboards
+// holding them in their model
+func identity(content []byte) (string, int64) {
+	var object struct {
+		UID       string `json:"uid"`
+		Version   int64  `json:"version"`
+		Dashboard *struct {
+			UID     string `json:"uid"`
+			Version int64  `json:"version"`
+		} `json:"dashboard"`
+	}
+	if err := json.Unmarshal(content, &object); err != nil {
+		return "", 0
+	}
+	if object.UID == "" && object.Dashboard != nil {
+		return object.Dashboard.UID, object.Dashboard.Version
+	}
+	return object.UID, object.Version
+}
+
+// Parse reads a manifest
+func Parse(data []byte) (*Manifest, error) {
+	m := &Manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", Name, err)
+	}
+	return m, nil
+}
+
+// Verify checks files, keyed by their path relative to the run directory,
+// against the manifest. It returns a description of every missing,
+// corrupt or unexpected file.
+func (m *Manifest) Verify(files map[string][]byte) []string {
+	var problems []string
+	if m.Count != len(m.Objects) {
+		problems = append(problems, fmt.Sprintf("%d objects expected, %d listed", m.Count, len(m.Objects)))
+	}
+	listedByType := make(map[gde.ValueType]int)
+	for _, o := range m.Objects {
+		listedByType[o.Type]++
+	}
+	var types []string
+	for t := range m.CountByType {
+		types = append(types, string(t))
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		if expected, listed := m.CountByType[gde.ValueType(t)], listedByType[gde.ValueType(t)]; expected != listed {
+			problems = append(problems, fmt.Sprintf("%d %s objects expected, %d listed", expected, t, listed))
+		}
+	}
+
+	listed := make(map[string]bool, len(m.Objects))
+	for _, o := range m.Objects {
+		listed[o.Path] = true
+		content, ok := files[o.Path]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: missing", o.Path))
+			continue
+		}
+		sum := sha256.Sum256(content)
+		if len(content) != o.Size || hex.EncodeToString(sum[:]) != o.SHA256 {
+			problems = append(problems, fmt.Sprintf("%s: checksum mismatch, corrupt or modified", o.Path))
+		}
+	}
+
+	var extra []string
+	for path := range files {
+		if !listed[path] && path != Name {
+			extra = append(extra, path)
+		}
+	}
+	sort.Strings(extra)
+	for _, path := range extra {
+		problems = append(problems, fmt.Sprintf("%s: not listed in the manifest", path))
+	}
+	return problems
+}
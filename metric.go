@@ -8,15 +8,103 @@ type Action string
 const (
 	TypeDatasource ValueType = "Datasource"
 	TypeDashboard  ValueType = "Dashboard"
-	ActionCreate   Action    = "Create"
-	ActionFinish   Action    = "Finish"
+	TypeFolder     ValueType = "Folder"
+
+	// Legacy alerting
+	TypeAlertNotification ValueType = "AlertNotification"
+
+	// Unified alerting
+	TypeContactPoint         ValueType = "ContactPoint"
+	TypePolicyTree           ValueType = "PolicyTree"
+	TypeMuteTiming           ValueType = "MuteTiming"
+	TypeNotificationTemplate ValueType = "NotificationTemplate"
+	TypeAlertRule            ValueType = "AlertRule"
+
+	// Access
+	TypeUser                ValueType = "User"
+	TypeTeam                ValueType = "Team"
+	TypeDashboardPermission ValueType = "DashboardPermission"
+
+	ActionCreate Action = "Create"
+	ActionFinish Action = "Finish"
 )
 
+// Possible keys of the metadata of a metric
+const (
+	// MetaUID is the grafana uid of the object
+	MetaUID = "uid"
+	// MetaOrgID is the id of the org the object belongs to
+	MetaOrgID = "org_id"
+	// MetaVersion is the version of the object, incremented by grafana on
+	// every save
+	MetaVersion = "version"
+	// MetaFolderUID is the uid of the folder the object is placed in
+	MetaFolderUID = "folder_uid"
+	// MetaTags are the tags of a dashboard
+	MetaTags = "tags"
+	// MetaURL is the path of the object in the grafana UI
+	MetaURL = "url"
+	// MetaCounts is the number of objects of every type the input emitted
+	// for a run, set on its finish metric
+	MetaCounts = "counts"
+)
+
+// Metadata describes the exported object beyond its content, so that
+// outputs do not need to parse it. Values are strings, int64, []string or
+// map[string]int64, read them with the typed accessors.
+type Metadata map[string]interface{}
+
+// String returns the string value of key, empty when it is not set
+func (m Metadata) String(key string) string {
+	s, _ := m[key].(string)
+	return s
+}
+
+// Int returns the integer value of key, 0 when it is not set
+func (m Metadata) Int(key string) int64 {
+	switch v := m[key].(type) {
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
+
+// Strings returns the list value of key, nil when it is not set
+func (m Metadata) Strings(key string) []string {
+	s, _ := m[key].([]string)
+	return s
+}
+
+// Counts returns the map value of key, nil when it is not set
+func (m Metadata) Counts(key string) map[string]int64 {
+	c, _ := m[key].(map[string]int64)
+	return c
+}
+
 type Metric interface {
 	// Getting data structure functions
 	Dir() string
 	Type() ValueType
 	Action() Action
+	// UID is the grafana uid of the object, empty for objects which have
+	// none
+	UID() string
 	Title() string
+	// Folder is the title of the folder the object is placed in, empty for
+	// objects which are not placed in a folder
+	Folder() string
 	Content() []byte
+
+	// Metadata describes the object, it is never nil
+	Metadata() Metadata
+	// OrgID, Version, FolderUID and Tags are shorthands for the values of
+	// the metadata
+	OrgID() int64
+	Version() int64
+	FolderUID() string
+	Tags() []string
 }
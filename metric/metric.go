@@ -5,11 +5,13 @@ import (
 )
 
 type metric struct {
-	dir     string
-	mType   gde.ValueType
-	action  gde.Action
-	title   string
-	content []byte
+	dir      string
+	mType    gde.ValueType
+	action   gde.Action
+	title    string
+	folder   string
+	content  []byte
+	metadata gde.Metadata
 }
 
 func (m *metric) Dir() string {
@@ -24,14 +26,45 @@ func (m *metric) Action() gde.Action {
 	return m.action
 }
 
+func (m *metric) UID() string {
+	return m.metadata.String(gde.MetaUID)
+}
+
 func (m *metric) Title() string {
 	return m.title
 }
 
+func (m *metric) Folder() string {
+	return m.folder
+}
+
 func (m *metric) Content() []byte {
 	return m.content
 }
 
-func New(dir string, mType gde.ValueType, action gde.Action, title string, content []byte) *metric {
-	return &metric{dir: dir, mType: mType, action: action, title: title, content: content}
+func (m *metric) Metadata() gde.Metadata {
+	return m.metadata
+}
+
+func (m *metric) OrgID() int64 {
+	return m.metadata.Int(gde.MetaOrgID)
+}
+
+func (m *metric) Version() int64 {
+	return m.metadata.Int(gde.MetaVersion)
+}
+
+func (m *metric) FolderUID() string {
+	return m.metadata.String(gde.MetaFolderUID)
+}
+
+func (m *metric) Tags() []string {
+	return m.metadata.Strings(gde.MetaTags)
+}
+
+func New(dir string, mType gde.ValueType, action gde.Action, title string, folder string, content []byte, metadata gde.Metadata) *metric {
+	if metadata == nil {
+		metadata = gde.Metadata{}
+	}
+	return &metric{dir: dir, mType: mType, action: action, title: title, folder: folder, content: content, metadata: metadata}
 }
@@ -0,0 +1,84 @@
+package grafana
+
+import (
+	"github.com/vikramjakhr/grafana-dashboard-exporter"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/plugins/inputs/grafana/api"
+)
+
+// userExport is the json written for a user of the org. Ids, avatars and
+// the last seen times are left out, they are specific to the instance and
+// change without the user changing.
+type userExport struct {
+	Login      string   `json:"login"`
+	Name       string   `json:"name"`
+	Email      string   `json:"email"`
+	Role       string   `json:"role"`
+	IsDisabled bool     `json:"isDisabled"`
+	AuthLabels []string `json:"authLabels,omitempty"`
+}
+
+// teamExport is the json written for a team along with its members, keyed
+// by login
+type teamExport struct {
+	Uid     string         `json:"uid,omitempty"`
+	Name    string         `json:"name"`
+	Email   string         `json:"email"`
+	Members []memberExport `json:"members"`
+}
+
+// memberExport is a member of a team, Permission being 4 for the admins of
+// the team
+type memberExport struct {
+	Login      string `json:"login"`
+	Permission int64  `json:"permission"`
+}
+
+// processAccess exports the users of the org and its teams with their
+// members
+func (s *Grafana) processAccess(acc gde.Accumulator, gClient *api.GrafanaClient, dir string, org *api.Org) error {
+	if s.User {
+		users, err := gClient.GetOrgUsers()
+		if err != nil {
+			return err
+		}
+		for _, u := range users {
+			user := userExport{
+				Login:      u.Login,
+				Name:       u.Name,
+				Email:      u.Email,
+				Role:       u.Role,
+				IsDisabled: u.IsDisabled,
+				AuthLabels: u.AuthLabels,
+			}
+			if err := addJSON(acc, dir, gde.TypeUser, u.Login, user, meta(org, "")); err != nil {
+				return err
+			}
+		}
+	}
+
+	if s.Team {
+		teams, err := gClient.GetTeams()
+		if err != nil {
+			return err
+		}
+		for _, t := range teams {
+			members, err := gClient.GetTeamMembers(t.Id)
+			if err != nil {
+				return err
+			}
+			team := teamExport{
+				Uid:     t.Uid,
+				Name:    t.Name,
+				Email:   t.Email,
+				Members: make([]memberExport, 0, len(members)),
+			}
+			for _, m := range members {
+				team.Members = append(team.Members, memberExport{m.Login, m.Permission})
+			}
+			if err := addJSON(acc, dir, gde.TypeTeam, t.Name, team, meta(org, t.Uid)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package grafana
+
+import (
+	"encoding/json"
+
+	"github.com/vikramjakhr/grafana-dashboard-exporter"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/plugins/inputs/grafana/api"
+)
+
+// alerting reports whether any alerting resource is to be exported
+func (s *Grafana) alerting() bool {
+	return s.AlertNotification || s.ContactPoint || s.NotificationPolicy ||
+		s.MuteTiming || s.NotificationTemplate || s.AlertRule
+}
+
+// processAlerting exports the legacy and unified alerting resources
+func (s *Grafana) processAlerting(acc gde.Accumulator, gClient *api.GrafanaClient, dir string, org *api.Org) error {
+	if s.AlertNotification {
+		notifications, err := gClient.GetAlertNotifications()
+		if err != nil {
+			return err
+		}
+		for _, n := range notifications {
+			if err := addJSON(acc, dir, gde.TypeAlertNotification, n.Name, n, meta(org, n.Uid)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if s.ContactPoint {
+		contactPoints, err := gClient.GetContactPoints()
+		if err != nil {
+			return err
+		}
+		for _, cp := range contactPoints {
+			// a contact point name is shared by all of its integrations,
+			// the outputs tell them apart by uid
+			if err := addJSON(acc, dir, gde.TypeContactPoint, cp.Name, cp, meta(org, cp.Uid)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if s.NotificationPolicy {
+		tree, err := gClient.GetPolicyTree()
+		if err != nil {
+			return err
+		}
+		if err := addJSON(acc, dir, gde.TypePolicyTree, "NotificationPolicies", tree, meta(org, "")); err != nil {
+			return err
+		}
+	}
+
+	if s.MuteTiming {
+		muteTimings, err := gClient.GetMuteTimings()
+		if err != nil {
+			return err
+		}
+		for _, mt := range muteTimings {
+			if err := addJSON(acc, dir, gde.TypeMuteTiming, mt.Name, mt, meta(org, "")); err != nil {
+				return err
+			}
+		}
+	}
+
+	if s.NotificationTemplate {
+		templates, err := gClient.GetNotificationTemplates()
+		if err != nil {
+			return err
+		}
+		for _, t := range templates {
+			if err := addJSON(acc, dir, gde.TypeNotificationTemplate, t.Name, t, meta(org, "")); err != nil {
+				return err
+			}
+		}
+	}
+
+	if s.AlertRule {
+		rules, err := gClient.GetAlertRules()
+		if err != nil {
+			return err
+		}
+		for _, r := range rules {
+			metadata := meta(org, r.Uid)
+			metadata[gde.MetaFolderUID] = r.FolderUID
+			if err := addJSON(acc, dir, gde.TypeAlertRule, r.Title, r, metadata); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addJSON marshals v and adds it to the accumulator as an object of the
+// given type
+func addJSON(acc gde.Accumulator, dir string, valueType gde.ValueType, title string, v interface{}, metadata gde.Metadata) error {
+	byts, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	acc.AddOutput(dir, valueType, gde.ActionCreate, title, "", byts, metadata)
+	return nil
+}
+
+// meta returns the metadata of an object of org, uid being empty for the
+// objects which have none
+func meta(org *api.Org, uid string) gde.Metadata {
+	return gde.Metadata{
+		gde.MetaUID:   uid,
+		gde.MetaOrgID: org.Id,
+	}
+}
@@ -0,0 +1,100 @@
+package api
+
+// AlertNotification is a legacy alerting notification channel
+type AlertNotification struct {
+	Id                    int64                  `json:"id"`
+	Uid                   string                 `json:"uid"`
+	Name                  string                 `json:"name"`
+	Type                  string                 `json:"type"`
+	IsDefault             bool                   `json:"isDefault"`
+	SendReminder          bool                   `json:"sendReminder"`
+	DisableResolveMessage bool                   `json:"disableResolveMessage"`
+	Frequency             string                 `json:"frequency,omitempty"`
+	Settings              map[string]interface{} `json:"settings"`
+	SecureFields          map[string]bool        `json:"secureFields,omitempty"`
+}
+
+// ContactPoint is a unified alerting contact point
+type ContactPoint struct {
+	Uid                   string                 `json:"uid"`
+	Name                  string                 `json:"name"`
+	Type                  string                 `json:"type"`
+	Settings              map[string]interface{} `json:"settings"`
+	DisableResolveMessage bool                   `json:"disableResolveMessage"`
+	Provenance            string                 `json:"provenance,omitempty"`
+}
+
+// PolicyTree is the unified alerting notification policy tree, kept as is
+// since routes nest arbitrarily deep.
+type PolicyTree map[string]interface{}
+
+// MuteTiming is a unified alerting mute timing
+type MuteTiming struct {
+	Name          string                   `json:"name"`
+	TimeIntervals []map[string]interface{} `json:"time_intervals"`
+	Provenance    string                   `json:"provenance,omitempty"`
+}
+
+// NotificationTemplate is a unified alerting notification template
+type NotificationTemplate struct {
+	Name       string `json:"name"`
+	Template   string `json:"template"`
+	Provenance string `json:"provenance,omitempty"`
+}
+
+// AlertRule is a grafana managed unified alerting rule
+type AlertRule struct {
+	Id           int64                    `json:"id,omitempty"`
+	Uid          string                   `json:"uid"`
+	OrgID        int64                    `json:"orgID"`
+	FolderUID    string                   `json:"folderUID"`
+	RuleGroup    string                   `json:"ruleGroup"`
+	Title        string                   `json:"title"`
+	Condition    string                   `json:"condition"`
+	Data         []map[string]interface{} `json:"data"`
+	Updated      string                   `json:"updated,omitempty"`
+	NoDataState  string                   `json:"noDataState"`
+	ExecErrState string                   `json:"execErrState"`
+	For          string                   `json:"for"`
+	Annotations  map[string]string        `json:"annotations,omitempty"`
+	Labels       map[string]string        `json:"labels,omitempty"`
+	IsPaused     bool                     `json:"isPaused"`
+	Provenance   string                   `json:"provenance,omitempty"`
+}
+
+// GetAlertNotifications returns the legacy alerting notification channels
+func (c *GrafanaClient) GetAlertNotifications() ([]AlertNotification, error) {
+	notifications := make([]AlertNotification, 0)
+	err := c.get("/api/alert-notifications", &notifications)
+	return notifications, err
+}
+
+func (c *GrafanaClient) GetContactPoints() ([]ContactPoint, error) {
+	contactPoints := make([]ContactPoint, 0)
+	err := c.get("/api/v1/provisioning/contact-points", &contactPoints)
+	return contactPoints, err
+}
+
+func (c *GrafanaClient) GetPolicyTree() (PolicyTree, error) {
+	tree := make(PolicyTree)
+	err := c.get("/api/v1/provisioning/policies", &tree)
+	return tree, err
+}
+
+func (c *GrafanaClient) GetMuteTimings() ([]MuteTiming, error) {
+	muteTimings := make([]MuteTiming, 0)
+	err := c.get("/api/v1/provisioning/mute-timings", &muteTimings)
+	return muteTimings, err
+}
+
+func (c *GrafanaClient) GetNotificationTemplates() ([]NotificationTemplate, error) {
+	templates := make([]NotificationTemplate, 0)
+	err := c.get("/api/v1/provisioning/templates", &templates)
+	return templates, err
+}
+
+func (c *GrafanaClient) GetAlertRules() ([]AlertRule, error) {
+	rules := make([]AlertRule, 0)
+	err := c.get("/api/v1/provisioning/alert-rules", &rules)
+	return rules, err
+}
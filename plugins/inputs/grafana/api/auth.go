@@ -0,0 +1,61 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+)
+
+// DefaultProxyHeader is the header grafana reads the user of the auth proxy
+// from by default
+const DefaultProxyHeader = "X-WEBAUTH-USER"
+
+// Auth holds the credentials of a client, exactly one of an api key or
+// service account token, a username and password or an auth proxy user
+type Auth struct {
+	// Token is an api key or a service account token, sent as bearer token
+	Token string
+	// Username and Password are basic auth credentials
+	Username string
+	Password string
+	// ProxyUser is the user sent in the ProxyHeader header to a grafana
+	// behind an auth proxy, DefaultProxyHeader when ProxyHeader is empty
+	ProxyHeader string
+	ProxyUser   string
+}
+
+// Validate checks that a single authentication scheme is set
+func (a Auth) Validate() error {
+	schemes := 0
+	if a.Token != "" {
+		schemes++
+	}
+	if a.Username != "" {
+		schemes++
+	}
+	if a.ProxyUser != "" {
+		schemes++
+	}
+	switch {
+	case schemes == 0:
+		return errors.New("no grafana credentials, set an api key, a service account token, a username and password or an auth proxy user")
+	case schemes > 1:
+		return errors.New("several grafana credentials set, only one of an api key, a service account token, a username and password or an auth proxy user can be used")
+	}
+	return nil
+}
+
+// apply authenticates req
+func (a Auth) apply(req *http.Request) {
+	switch {
+	case a.Token != "":
+		req.Header.Set("Authorization", "Bearer "+a.Token)
+	case a.Username != "":
+		req.SetBasicAuth(a.Username, a.Password)
+	case a.ProxyUser != "":
+		header := a.ProxyHeader
+		if header == "" {
+			header = DefaultProxyHeader
+		}
+		req.Header.Set(header, a.ProxyUser)
+	}
+}
@@ -1,43 +1,66 @@
 package api
 
 import (
-	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"path"
-	"strings"
+	"strconv"
+	"time"
 )
 
 type GrafanaClient struct {
-	key     string
+	auth    Auth
 	baseURL url.URL
+	// orgId is sent as X-Grafana-Org-Id header when set, switching the
+	// organization requests are made against
+	orgId int64
+	// MaxRetries is the number of times a failed request is retried and
+	// RetryBackoff the wait before the first retry, doubled on every retry
+	MaxRetries   int
+	RetryBackoff time.Duration
+	// SearchPageSize is the number of results requested per search page,
+	// DefaultSearchPageSize when 0
+	SearchPageSize int
 	*http.Client
 }
 
-//New creates a new grafana client
-//auth can be in user:pass format, or it can be an api key
-func NewGrafanaClient(auth, baseURL string) (*GrafanaClient, error) {
+// NewGrafanaClient creates a new grafana client authenticating with auth
+func NewGrafanaClient(auth Auth, baseURL string) (*GrafanaClient, error) {
+	if err := auth.Validate(); err != nil {
+		return nil, err
+	}
 	u, err := url.Parse(baseURL)
 	if err != nil {
 		return nil, err
 	}
-	key := ""
-	if strings.Contains(auth, ":") {
-		split := strings.Split(auth, ":")
-		u.User = url.UserPassword(split[0], split[1])
-	} else {
-		key = fmt.Sprintf("Bearer %s", auth)
-	}
 	return &GrafanaClient{
-		key,
-		*u,
-		&http.Client{},
+		auth:    auth,
+		baseURL: *u,
+		Client:  &http.Client{},
 	}, nil
 }
 
+// UserAuth reports whether the client authenticates as a user, with basic
+// auth or through the auth proxy, rather than with a token, which is bound
+// to a single org.
+func (c *GrafanaClient) UserAuth() bool {
+	return c.auth.Username != "" || c.auth.ProxyUser != ""
+}
+
+// WithOrg returns a copy of the client making its requests against the
+// organization with the given id. Switching organizations requires the
+// client to authenticate as a user which is a member of that organization.
+func (c *GrafanaClient) WithOrg(orgId int64) *GrafanaClient {
+	client := *c
+	client.orgId = orgId
+	return &client
+}
+
 func (c *GrafanaClient) newRequest(method, requestPath string, body io.Reader) (*http.Request, error) {
 	gURL := c.baseURL
 	gURL.Path = path.Join(gURL.Path, requestPath)
@@ -45,16 +68,44 @@ func (c *GrafanaClient) newRequest(method, requestPath string, body io.Reader) (
 	if err != nil {
 		return req, err
 	}
-	if c.key != "" {
-		req.Header.Add("Authorization", c.key)
+	c.auth.apply(req)
+	if c.orgId != 0 {
+		req.Header.Add("X-Grafana-Org-Id", strconv.FormatInt(c.orgId, 10))
 	}
 
-	if body == nil {
-		log.Printf("D! Request to %s with empty body data", gURL.String())
-	} else {
-		log.Printf("D! Request to %s with body data %s", gURL.String(), body.(*bytes.Buffer).String())
-	}
+	// bodies hold datasource secrets, only their size is logged
+	log.Printf("D! %s request to %s, %d bytes of body data", method, gURL.Path, req.ContentLength)
 
 	req.Header.Add("Content-Type", "application/json")
 	return req, err
 }
+
+// get requests path and decodes the json response into v
+func (c *GrafanaClient) get(path string, v interface{}) error {
+	return c.getQuery(path, nil, v)
+}
+
+// getQuery requests path with the query parameters and decodes the json
+// response into v
+func (c *GrafanaClient) getQuery(path string, query url.Values, v interface{}) error {
+	req, err := c.newRequest("GET", path, nil)
+	if err != nil {
+		return err
+	}
+	if query != nil {
+		req.URL.RawQuery = query.Encode()
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("GET %s: %s", path, resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
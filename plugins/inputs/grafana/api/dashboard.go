@@ -1,24 +1,59 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/url"
 )
 
 type DashboardMeta struct {
-	IsStarred bool   `json:"isStarred"`
-	URL       string `json:"url"`
+	Type        string `json:"type"`
+	IsStarred   bool   `json:"isStarred"`
+	IsFolder    bool   `json:"isFolder"`
+	Slug        string `json:"slug"`
+	URL         string `json:"url"`
+	Version     int64  `json:"version"`
+	Created     string `json:"created"`
+	Updated     string `json:"updated"`
+	CreatedBy   string `json:"createdBy"`
+	UpdatedBy   string `json:"updatedBy"`
+	HasAcl      bool   `json:"hasAcl"`
+	FolderId    int64  `json:"folderId"`
+	FolderUid   string `json:"folderUid"`
+	FolderTitle string `json:"folderTitle"`
+	FolderUrl   string `json:"folderUrl"`
+	Provisioned bool   `json:"provisioned"`
 }
 
 type Dashboard struct {
-	Meta  DashboardMeta          `json:"meta"`
 	Model map[string]interface{} `json:"dashboard"`
+	Meta  DashboardMeta          `json:"meta"`
+}
+
+// DashboardSaveRequest is the body accepted by POST /api/dashboards/db
+type DashboardSaveRequest struct {
+	Model     map[string]interface{} `json:"dashboard"`
+	FolderId  int64                  `json:"folderId,omitempty"`
+	FolderUid string                 `json:"folderUid,omitempty"`
+	Overwrite bool                   `json:"overwrite"`
+	Message   string                 `json:"message,omitempty"`
+}
+
+// GetDashboard returns the dashboard with the given uid
+func (c *GrafanaClient) GetDashboard(uid string) (*Dashboard, error) {
+	return c.getDashboard(fmt.Sprintf("/api/dashboards/uid/%s", url.PathEscape(uid)))
+}
+
+// GetDashboardByUri returns the dashboard with the given slug based uri, ie,
+// "db/<slug>". It is only meant for grafana releases without dashboard uids.
+func (c *GrafanaClient) GetDashboardByUri(uri string) (*Dashboard, error) {
+	return c.getDashboard(fmt.Sprintf("/api/dashboards/%s", uri))
 }
 
-func (c *GrafanaClient) GetDashboard(uri string) (*Dashboard, error) {
-	path := fmt.Sprintf("/api/dashboards/%s", uri)
+func (c *GrafanaClient) getDashboard(path string) (*Dashboard, error) {
 	req, err := c.newRequest("GET", path, nil)
 	if err != nil {
 		return nil, err
@@ -28,6 +63,7 @@ func (c *GrafanaClient) GetDashboard(uri string) (*Dashboard, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
 		return nil, errors.New(resp.Status)
 	}
@@ -41,3 +77,26 @@ func (c *GrafanaClient) GetDashboard(uri string) (*Dashboard, error) {
 	err = json.Unmarshal(data, &result)
 	return result, err
 }
+
+// SaveDashboard creates or updates a dashboard from the given model.
+func (c *GrafanaClient) SaveDashboard(save *DashboardSaveRequest) error {
+	data, err := json.Marshal(save)
+	if err != nil {
+		return err
+	}
+	req, err := c.newRequest("POST", "/api/dashboards/db", bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		data, _ = ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, data)
+	}
+	return nil
+}
@@ -1,13 +1,17 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
+	"net/url"
 )
 
 type DataSource struct {
 	Id     int64  `json:"id,omitempty"`
+	Uid    string `json:"uid,omitempty"`
 	Name   string `json:"name"`
 	Type   string `json:"type"`
 	URL    string `json:"url"`
@@ -17,8 +21,10 @@ type DataSource struct {
 	User     string `json:"user,omitempty"`
 	Password string `json:"password,omitempty"`
 
-	OrgId     int64 `json:"orgId,omitempty"`
-	IsDefault bool  `json:"isDefault"`
+	OrgId           int64 `json:"orgId,omitempty"`
+	IsDefault       bool  `json:"isDefault"`
+	WithCredentials bool  `json:"withCredentials"`
+	ReadOnly        bool  `json:"readOnly,omitempty"`
 
 	BasicAuth         bool   `json:"basicAuth"`
 	BasicAuthUser     string `json:"basicAuthUser,omitempty"`
@@ -26,21 +32,19 @@ type DataSource struct {
 
 	JSONData       JSONData       `json:"jsonData,omitempty"`
 	SecureJSONData SecureJSONData `json:"secureJsonData,omitempty"`
+	// SecureJSONFields names the secureJsonData keys which are set, grafana
+	// never returns their values
+	SecureJSONFields map[string]bool `json:"secureJsonFields,omitempty"`
 }
 
-// JSONData is a representation of the datasource `jsonData` property
-type JSONData struct {
-	AssumeRoleArn           string `json:"assumeRoleArn,omitempty"`
-	AuthType                string `json:"authType,omitempty"`
-	CustomMetricsNamespaces string `json:"customMetricsNamespaces,omitempty"`
-	DefaultRegion           string `json:"defaultRegion,omitempty"`
-}
+// JSONData is the datasource `jsonData` property, kept as is since its
+// settings depend on the datasource type, ie, tlsAuth, httpMethod,
+// timeInterval or the index settings
+type JSONData map[string]interface{}
 
-// SecureJSONData is a representation of the datasource `secureJsonData` property
-type SecureJSONData struct {
-	AccessKey string `json:"accessKey,omitempty"`
-	SecretKey string `json:"secretKey,omitempty"`
-}
+// SecureJSONData is a representation of the datasource `secureJsonData`
+// property, ie, accessKey, secretKey, password or httpHeaderValue1
+type SecureJSONData map[string]string
 
 func (c *GrafanaClient) GetDataSources() (*[]DataSource, error) {
 	dataSources := make([]DataSource, 0)
@@ -65,3 +69,90 @@ func (c *GrafanaClient) GetDataSources() (*[]DataSource, error) {
 	err = json.Unmarshal(data, &dataSources)
 	return &dataSources, err
 }
+
+// GetDataSourceByName returns the datasource with the given name, or nil if
+// no such datasource exists.
+func (c *GrafanaClient) GetDataSourceByName(name string) (*DataSource, error) {
+	req, err := c.newRequest("GET", fmt.Sprintf("/api/datasources/name/%s", url.PathEscape(name)), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == 404 {
+		return nil, nil
+	}
+	if resp.StatusCode != 200 {
+		return nil, errors.New(resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	ds := &DataSource{}
+	err = json.Unmarshal(data, ds)
+	return ds, err
+}
+
+// NewDataSource creates the given datasource and returns its id. ds is a
+// DataSource or the datasource json decoded as is.
+func (c *GrafanaClient) NewDataSource(ds interface{}) (int64, error) {
+	data, err := json.Marshal(ds)
+	if err != nil {
+		return 0, err
+	}
+	req, err := c.newRequest("POST", "/api/datasources", bytes.NewBuffer(data))
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	data, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != 200 {
+		return 0, fmt.Errorf("%s: %s", resp.Status, data)
+	}
+
+	result := struct {
+		Id int64 `json:"id"`
+	}{}
+	err = json.Unmarshal(data, &result)
+	return result.Id, err
+}
+
+// UpdateDataSource replaces the datasource with the given id by ds, a
+// DataSource or the datasource json decoded as is.
+func (c *GrafanaClient) UpdateDataSource(id int64, ds interface{}) error {
+	data, err := json.Marshal(ds)
+	if err != nil {
+		return err
+	}
+	req, err := c.newRequest("PUT", fmt.Sprintf("/api/datasources/%d", id), bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		data, _ = ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, data)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+)
+
+type Folder struct {
+	Id        int64  `json:"id"`
+	Uid       string `json:"uid"`
+	Title     string `json:"title"`
+	Url       string `json:"url,omitempty"`
+	HasAcl    bool   `json:"hasAcl"`
+	Version   int64  `json:"version,omitempty"`
+	Created   string `json:"created,omitempty"`
+	Updated   string `json:"updated,omitempty"`
+	UpdatedBy string `json:"updatedBy,omitempty"`
+}
+
+// GetFolder returns the folder with the given uid, or nil if no such folder
+// exists.
+func (c *GrafanaClient) GetFolder(uid string) (*Folder, error) {
+	req, err := c.newRequest("GET", fmt.Sprintf("/api/folders/%s", url.PathEscape(uid)), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == 404 {
+		return nil, nil
+	}
+	if resp.StatusCode != 200 {
+		return nil, errors.New(resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	folder := &Folder{}
+	err = json.Unmarshal(data, folder)
+	return folder, err
+}
+
+// NewFolder creates a folder with the given uid and title
+func (c *GrafanaClient) NewFolder(uid, title string) (*Folder, error) {
+	data, err := json.Marshal(map[string]string{"uid": uid, "title": title})
+	if err != nil {
+		return nil, err
+	}
+	req, err := c.newRequest("POST", "/api/folders", bytes.NewBuffer(data))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("%s: %s", resp.Status, data)
+	}
+
+	folder := &Folder{}
+	err = json.Unmarshal(data, folder)
+	return folder, err
+}
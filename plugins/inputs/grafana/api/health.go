@@ -0,0 +1,16 @@
+package api
+
+// Health is the state of a grafana instance as reported by /api/health
+type Health struct {
+	Commit   string `json:"commit"`
+	Database string `json:"database"`
+	Version  string `json:"version"`
+}
+
+// GetHealth returns the health of the grafana instance, it does not
+// require any authentication
+func (c *GrafanaClient) GetHealth() (*Health, error) {
+	health := &Health{}
+	err := c.get("/api/health", health)
+	return health, err
+}
@@ -32,3 +32,27 @@ func (c *GrafanaClient) GetCurrentOrg() (*Org, error) {
 	err = json.Unmarshal(data, &org)
 	return org, err
 }
+
+// GetOrgs returns all organizations, it requires grafana admin credentials
+func (c *GrafanaClient) GetOrgs() ([]Org, error) {
+	orgs := make([]Org, 0)
+
+	req, err := c.newRequest("GET", "/api/orgs", nil)
+	if err != nil {
+		return orgs, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return orgs, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return orgs, errors.New(resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return orgs, err
+	}
+	err = json.Unmarshal(data, &orgs)
+	return orgs, err
+}
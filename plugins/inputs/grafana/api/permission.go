@@ -0,0 +1,84 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+)
+
+// Permission is a single entry of a folder or dashboard access control
+// list, granted to a user, a team or an org role
+type Permission struct {
+	UserId         int64  `json:"userId,omitempty"`
+	UserLogin      string `json:"userLogin,omitempty"`
+	TeamId         int64  `json:"teamId,omitempty"`
+	Team           string `json:"team,omitempty"`
+	Role           string `json:"role,omitempty"`
+	Permission     int64  `json:"permission"`
+	PermissionName string `json:"permissionName,omitempty"`
+	// Inherited is set on the entries of a dashboard inherited from its
+	// folder
+	Inherited bool `json:"inherited,omitempty"`
+}
+
+// PermissionItem is an entry of the access control list set by
+// UpdateFolderPermissions and UpdateDashboardPermissions
+type PermissionItem struct {
+	UserId     int64  `json:"userId,omitempty"`
+	TeamId     int64  `json:"teamId,omitempty"`
+	Role       string `json:"role,omitempty"`
+	Permission int64  `json:"permission"`
+}
+
+// GetFolderPermissions returns the access control list of the folder with
+// the given uid
+func (c *GrafanaClient) GetFolderPermissions(uid string) ([]Permission, error) {
+	permissions := make([]Permission, 0)
+	err := c.get(fmt.Sprintf("/api/folders/%s/permissions", url.PathEscape(uid)), &permissions)
+	return permissions, err
+}
+
+// GetDashboardPermissions returns the access control list of the dashboard
+// with the given uid, along with the entries inherited from its folder
+func (c *GrafanaClient) GetDashboardPermissions(uid string) ([]Permission, error) {
+	permissions := make([]Permission, 0)
+	err := c.get(fmt.Sprintf("/api/dashboards/uid/%s/permissions", url.PathEscape(uid)), &permissions)
+	return permissions, err
+}
+
+// UpdateFolderPermissions replaces the access control list of the folder
+// with the given uid
+func (c *GrafanaClient) UpdateFolderPermissions(uid string, items []PermissionItem) error {
+	return c.updatePermissions(fmt.Sprintf("/api/folders/%s/permissions", url.PathEscape(uid)), items)
+}
+
+// UpdateDashboardPermissions replaces the access control list of the
+// dashboard with the given uid, the entries inherited from its folder
+// being left as they are
+func (c *GrafanaClient) UpdateDashboardPermissions(uid string, items []PermissionItem) error {
+	return c.updatePermissions(fmt.Sprintf("/api/dashboards/uid/%s/permissions", url.PathEscape(uid)), items)
+}
+
+func (c *GrafanaClient) updatePermissions(path string, items []PermissionItem) error {
+	data, err := json.Marshal(map[string][]PermissionItem{"items": items})
+	if err != nil {
+		return err
+	}
+	req, err := c.newRequest("POST", path, bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, body)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxRetryAfter caps the wait asked for by the Retry-After header
+const maxRetryAfter = 5 * time.Minute
+
+// Do sends req, retrying up to MaxRetries times with an exponential backoff
+// when grafana is unavailable. Requests which were not processed, rejected
+// with 429 Too Many Requests, are retried whatever their method, other
+// failures only for requests which do not change anything.
+func (c *GrafanaClient) Do(req *http.Request) (*http.Response, error) {
+	backoff := c.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		resp, err := c.Client.Do(req)
+		if attempt >= c.MaxRetries || !retryable(req, resp, err) {
+			return resp, err
+		}
+
+		wait, reason := backoff, err
+		if resp != nil {
+			if after, ok := retryAfter(resp); ok && after > wait {
+				wait = after
+			}
+			ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			reason = errors.New(resp.Status)
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+		log.Printf("W! Request to %s failed: %s, retrying in %s (%d/%d)",
+			req.URL.Path, reason, wait, attempt+1, c.MaxRetries)
+		time.Sleep(wait)
+		backoff *= 2
+	}
+}
+
+// retryable reports whether the request may be sent again after resp or err
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	if req.Body != nil && req.GetBody == nil {
+		// the body was consumed and cannot be sent again
+		return false
+	}
+	if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	if req.Method != "GET" && req.Method != "HEAD" {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfter returns the wait asked for by the Retry-After header of resp,
+// given in seconds or as a date
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	header := resp.Header.Get("Retry-After")
+	if header == "" {
+		return 0, false
+	}
+	var wait time.Duration
+	if seconds, err := strconv.Atoi(header); err == nil {
+		wait = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(header); err == nil {
+		wait = date.Sub(time.Now())
+	} else {
+		return 0, false
+	}
+	if wait > maxRetryAfter {
+		wait = maxRetryAfter
+	}
+	return wait, true
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// server answers the n-th request with the n-th status, the last one once
+// they are exhausted, and counts the requests
+func server(t *testing.T, header http.Header, statuses ...int) (*httptest.Server, *int32) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&requests, 1)) - 1
+		if n >= len(statuses) {
+			n = len(statuses) - 1
+		}
+		if body, _ := ioutil.ReadAll(r.Body); r.Method == "POST" && string(body) != `{"a":1}` {
+			t.Errorf("request %d sent body %q", n+1, body)
+		}
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(statuses[n])
+		w.Write([]byte("[]"))
+	}))
+	return srv, &requests
+}
+
+func client(t *testing.T, url string) *GrafanaClient {
+	c, err := NewGrafanaClient(Auth{Token: "tok"}, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.MaxRetries = 3
+	c.RetryBackoff = time.Millisecond
+	return c
+}
+
+func TestDoRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		statuses []int
+		status   int
+		requests int32
+	}{
+		{"success", "GET", []int{200}, 200, 1},
+		{"unavailable then success", "GET", []int{503, 502, 200}, 200, 3},
+		{"gateway timeouts until retries run out", "GET", []int{504}, 504, 4},
+		{"client errors are not retried", "GET", []int{404}, 404, 1},
+		{"server errors are not retried", "GET", []int{500}, 500, 1},
+		{"writes are not retried when unavailable", "POST", []int{503, 200}, 503, 1},
+		{"writes are retried when rejected by the rate limit", "POST", []int{429, 429, 200}, 200, 3},
+	}
+	for _, tt := range tests {
+		srv, requests := server(t, nil, tt.statuses...)
+		c := client(t, srv.URL)
+		var body io.Reader
+		if tt.method == "POST" {
+			body = bytes.NewBufferString(`{"a":1}`)
+		}
+		req, err := c.newRequest(tt.method, "/api/test", body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+		} else {
+			resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.status)
+			}
+		}
+		if n := atomic.LoadInt32(requests); n != tt.requests {
+			t.Errorf("%s: %d requests, want %d", tt.name, n, tt.requests)
+		}
+		srv.Close()
+	}
+}
+
+func TestDoNetworkError(t *testing.T) {
+	srv, _ := server(t, nil, 200)
+	c := client(t, srv.URL)
+	srv.Close()
+
+	req, err := c.newRequest("GET", "/api/test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err := c.Do(req); err == nil {
+		t.Errorf("request to a closed server returned no error")
+	}
+	// 1ms, 2ms and 4ms of backoff
+	if elapsed := time.Since(start); elapsed < 7*time.Millisecond {
+		t.Errorf("gave up after %s, want the backoff doubled on every retry", elapsed)
+	}
+}
+
+func TestDoRetryAfter(t *testing.T) {
+	srv, requests := server(t, http.Header{"Retry-After": {"1"}}, 429, 200)
+	defer srv.Close()
+	c := client(t, srv.URL)
+
+	req, err := c.newRequest("GET", "/api/test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, want the second Retry-After asked for", elapsed)
+	}
+	if n := atomic.LoadInt32(requests); n != 2 {
+		t.Errorf("%d requests, want 2", n)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		header string
+		ok     bool
+		min    time.Duration
+		max    time.Duration
+	}{
+		{"", false, 0, 0},
+		{"soon", false, 0, 0},
+		{"30", true, 30 * time.Second, 30 * time.Second},
+		{"3600", true, maxRetryAfter, maxRetryAfter},
+		{now.Add(time.Minute).UTC().Format(http.TimeFormat), true, 58 * time.Second, time.Minute},
+		{now.Add(time.Hour).UTC().Format(http.TimeFormat), true, maxRetryAfter, maxRetryAfter},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{Header: http.Header{}}
+		if tt.header != "" {
+			resp.Header.Set("Retry-After", tt.header)
+		}
+		wait, ok := retryAfter(resp)
+		if ok != tt.ok || wait < tt.min || wait > tt.max {
+			t.Errorf("retryAfter(%q) = %s, %v, want between %s and %s, %v", tt.header, wait, ok, tt.min, tt.max, tt.ok)
+		}
+	}
+}
@@ -3,44 +3,124 @@ package api
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
+	"strconv"
 )
 
 type SearchResp struct {
-	Id    int64
-	Title string
-	Uri   string
-	Type  string
+	Id    int64  `json:"id"`
+	Uid   string `json:"uid"`
+	Title string `json:"title"`
+	// Uri is the slug based "db/<slug>" form, removed in newer grafana
+	// releases. Use Uid instead.
+	Uri         string   `json:"uri"`
+	Url         string   `json:"url"`
+	Type        string   `json:"type"`
+	Tags        []string `json:"tags"`
+	IsStarred   bool     `json:"isStarred"`
+	FolderId    int64    `json:"folderId"`
+	FolderUid   string   `json:"folderUid"`
+	FolderTitle string   `json:"folderTitle"`
+	FolderUrl   string   `json:"folderUrl"`
 }
 
 var (
-	SearchTypeDashDB = "dash-db"
+	SearchTypeDashDB     = "dash-db"
+	SearchTypeDashFolder = "dash-folder"
 )
 
+// SearchParams narrow a search down, empty params are left out
+type SearchParams struct {
+	// Tags are the tags the results must all have
+	Tags []string
+	// FolderIds are the folders the results are placed in, 0 being the
+	// General folder
+	FolderIds []int64
+	// DashboardUIDs are the uids of the dashboards to return, ignored
+	// before grafana 8
+	DashboardUIDs []string
+}
+
 func (c *GrafanaClient) Search(sType, query string) (*[]SearchResp, error) {
+	return c.SearchWith(sType, query, SearchParams{})
+}
+
+// DefaultSearchPageSize is the number of results of a search page when the
+// client has no SearchPageSize, the default limit of grafana
+const DefaultSearchPageSize = 1000
+
+// MaxSearchPageSize is the largest search page grafana returns, larger
+// limits being capped by the server
+const MaxSearchPageSize = 5000
+
+// SearchWith searches the objects of the given type narrowed down by params,
+// paging through the results until they are exhausted
+func (c *GrafanaClient) SearchWith(sType, query string, params SearchParams) (*[]SearchResp, error) {
 	result := make([]SearchResp, 0)
+	limit := c.SearchPageSize
+	if limit <= 0 {
+		limit = DefaultSearchPageSize
+	}
 
+	seen := make(map[string]bool)
+	for page := 1; ; page++ {
+		results, err := c.searchPage(sType, query, params, limit, page)
+		if err != nil {
+			return &result, err
+		}
+		added := 0
+		for _, r := range results {
+			key := fmt.Sprintf("%d/%s", r.Id, r.Uid)
+			if !seen[key] {
+				seen[key] = true
+				result = append(result, r)
+				added++
+			}
+		}
+		// grafana releases without paging return the first page again
+		if len(results) < limit || added == 0 {
+			return &result, nil
+		}
+	}
+}
+
+// searchPage returns a single page of the results of a search
+func (c *GrafanaClient) searchPage(sType, query string, params SearchParams, limit, page int) ([]SearchResp, error) {
 	req, err := c.newRequest("GET", "/api/search", nil)
 	if err != nil {
-		return &result, err
+		return nil, err
 	}
 
 	q := req.URL.Query()
 	q.Add("type", sType)
 	q.Add("query", query)
+	for _, tag := range params.Tags {
+		q.Add("tag", tag)
+	}
+	for _, id := range params.FolderIds {
+		q.Add("folderIds", strconv.FormatInt(id, 10))
+	}
+	for _, uid := range params.DashboardUIDs {
+		q.Add("dashboardUIDs", uid)
+	}
+	q.Add("limit", strconv.Itoa(limit))
+	q.Add("page", strconv.Itoa(page))
 	req.URL.RawQuery = q.Encode()
 
 	resp, err := c.Do(req)
 	if err != nil {
-		return &result, err
+		return nil, err
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
-		return &result, errors.New(resp.Status)
+		return nil, errors.New(resp.Status)
 	}
 	data, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return &result, err
+		return nil, err
 	}
-	err = json.Unmarshal(data, &result)
-	return &result, err
+	var results []SearchResp
+	err = json.Unmarshal(data, &results)
+	return results, err
 }
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// searchServer serves results page by page, or always the first page when
+// paging is false like grafana releases before 6, and records the queries
+func searchServer(t *testing.T, results []SearchResp, paging bool) (*httptest.Server, *[]url.Values) {
+	var queries []url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/search" {
+			t.Errorf("request to %s, want /api/search", r.URL.Path)
+		}
+		q := r.URL.Query()
+		queries = append(queries, q)
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		page, _ := strconv.Atoi(q.Get("page"))
+		if !paging || limit <= 0 {
+			limit, page = len(results), 1
+		}
+		start, end := (page-1)*limit, page*limit
+		if start > len(results) {
+			start = len(results)
+		}
+		if end > len(results) {
+			end = len(results)
+		}
+		json.NewEncoder(w).Encode(results[start:end])
+	}))
+	return srv, &queries
+}
+
+func dashboards(uids ...string) []SearchResp {
+	var results []SearchResp
+	for i, uid := range uids {
+		results = append(results, SearchResp{Id: int64(i + 1), Uid: uid, Title: uid, Type: SearchTypeDashDB})
+	}
+	return results
+}
+
+func uids(results *[]SearchResp) []string {
+	var u []string
+	for _, r := range *results {
+		u = append(u, r.Uid)
+	}
+	return u
+}
+
+func TestSearchPaging(t *testing.T) {
+	tests := []struct {
+		name     string
+		results  []SearchResp
+		paging   bool
+		pageSize int
+		want     []string
+		pages    int
+	}{
+		{"single page", dashboards("a", "b"), true, 0, []string{"a", "b"}, 1},
+		{"pages", dashboards("a", "b", "c", "d", "e"), true, 2, []string{"a", "b", "c", "d", "e"}, 3},
+		{"full last page", dashboards("a", "b", "c", "d"), true, 2, []string{"a", "b", "c", "d"}, 3},
+		{"no results", nil, true, 2, nil, 1},
+		{"no paging", dashboards("a", "b", "c"), false, 3, []string{"a", "b", "c"}, 2},
+		{"repeated results", append(dashboards("a", "b"), dashboards("a", "c")...), true, 2, []string{"a", "b", "c"}, 3},
+	}
+	for _, tt := range tests {
+		srv, queries := searchServer(t, tt.results, tt.paging)
+		c := client(t, srv.URL)
+		c.SearchPageSize = tt.pageSize
+
+		results, err := c.Search(SearchTypeDashDB, "")
+		srv.Close()
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if got := uids(results); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: results = %v, want %v", tt.name, got, tt.want)
+		}
+		if len(*queries) != tt.pages {
+			t.Errorf("%s: %d pages requested, want %d", tt.name, len(*queries), tt.pages)
+		}
+		for i, q := range *queries {
+			if q.Get("page") != strconv.Itoa(i+1) {
+				t.Errorf("%s: page %s requested, want %d", tt.name, q.Get("page"), i+1)
+			}
+		}
+	}
+}
+
+func TestSearchParams(t *testing.T) {
+	srv, queries := searchServer(t, nil, true)
+	defer srv.Close()
+	c := client(t, srv.URL)
+
+	params := SearchParams{Tags: []string{"prod", "api"}, FolderIds: []int64{0, 12}, DashboardUIDs: []string{"abc"}}
+	if _, err := c.SearchWith(SearchTypeDashDB, "latency", params); err != nil {
+		t.Fatal(err)
+	}
+	want := url.Values{
+		"type":          {SearchTypeDashDB},
+		"query":         {"latency"},
+		"tag":           {"prod", "api"},
+		"folderIds":     {"0", "12"},
+		"dashboardUIDs": {"abc"},
+		"limit":         {strconv.Itoa(DefaultSearchPageSize)},
+		"page":          {"1"},
+	}
+	if len(*queries) != 1 || !reflect.DeepEqual((*queries)[0], want) {
+		t.Errorf("queries = %v, want %v", *queries, want)
+	}
+}
+
+func TestSearchError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	if _, err := client(t, srv.URL).Search(SearchTypeDashDB, ""); err == nil {
+		t.Errorf("search answered with 403 returned no error")
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// OrgUser is a user of an org as returned by /api/org/users
+type OrgUser struct {
+	UserId     int64    `json:"userId"`
+	Login      string   `json:"login"`
+	Name       string   `json:"name"`
+	Email      string   `json:"email"`
+	Role       string   `json:"role"`
+	IsDisabled bool     `json:"isDisabled"`
+	AuthLabels []string `json:"authLabels"`
+	AvatarUrl  string   `json:"avatarUrl"`
+	LastSeenAt string   `json:"lastSeenAt"`
+}
+
+// Team is a team of an org
+type Team struct {
+	Id          int64  `json:"id"`
+	Uid         string `json:"uid"`
+	Name        string `json:"name"`
+	Email       string `json:"email"`
+	AvatarUrl   string `json:"avatarUrl"`
+	MemberCount int64  `json:"memberCount"`
+}
+
+// TeamSearchResp is a page of /api/teams/search
+type TeamSearchResp struct {
+	TotalCount int64  `json:"totalCount"`
+	Teams      []Team `json:"teams"`
+	Page       int    `json:"page"`
+	PerPage    int    `json:"perPage"`
+}
+
+// TeamMember is a member of a team, Permission being 4 for the admins of
+// the team
+type TeamMember struct {
+	UserId     int64    `json:"userId"`
+	Login      string   `json:"login"`
+	Name       string   `json:"name"`
+	Email      string   `json:"email"`
+	Labels     []string `json:"labels"`
+	Permission int64    `json:"permission"`
+}
+
+// GetOrgUsers returns the users of the org
+func (c *GrafanaClient) GetOrgUsers() ([]OrgUser, error) {
+	users := make([]OrgUser, 0)
+	err := c.get("/api/org/users", &users)
+	return users, err
+}
+
+// GetTeams returns every team of the org, paging through the team search
+func (c *GrafanaClient) GetTeams() ([]Team, error) {
+	teams := make([]Team, 0)
+	perPage := c.SearchPageSize
+	if perPage <= 0 {
+		perPage = DefaultSearchPageSize
+	}
+
+	for page := 1; ; page++ {
+		q := url.Values{}
+		q.Set("perpage", strconv.Itoa(perPage))
+		q.Set("page", strconv.Itoa(page))
+		var resp TeamSearchResp
+		if err := c.getQuery("/api/teams/search", q, &resp); err != nil {
+			return teams, err
+		}
+		teams = append(teams, resp.Teams...)
+		if len(resp.Teams) < perPage || int64(len(teams)) >= resp.TotalCount {
+			return teams, nil
+		}
+	}
+}
+
+// GetTeamMembers returns the members of the team with the given id
+func (c *GrafanaClient) GetTeamMembers(teamId int64) ([]TeamMember, error) {
+	members := make([]TeamMember, 0)
+	err := c.get(fmt.Sprintf("/api/teams/%d/members", teamId), &members)
+	return members, err
+}
@@ -0,0 +1,77 @@
+package grafana
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"strings"
+
+	"github.com/vikramjakhr/grafana-dashboard-exporter/plugins/inputs/grafana/api"
+)
+
+// auth returns the credentials of the client from the auth options, or from
+// the deprecated authorization option when none is set
+func (s *Grafana) auth() (api.Auth, error) {
+	apiKey, err := secretOption(s.APIKey, s.APIKeyFile)
+	if err != nil {
+		return api.Auth{}, err
+	}
+	token, err := secretOption(s.ServiceAccountToken, s.ServiceAccountTokenFile)
+	if err != nil {
+		return api.Auth{}, err
+	}
+	password, err := secretOption(s.Password, s.PasswordFile)
+	if err != nil {
+		return api.Auth{}, err
+	}
+	if apiKey != "" && token != "" {
+		return api.Auth{}, errors.New("api_key and service_account_token cannot be set together")
+	}
+	if token == "" {
+		token = apiKey
+	}
+
+	auth := api.Auth{
+		Token:       token,
+		Username:    s.Username,
+		Password:    password,
+		ProxyHeader: s.AuthProxyHeader,
+		ProxyUser:   s.AuthProxyUser,
+	}
+	if s.Authorization != "" {
+		if auth != (api.Auth{ProxyHeader: s.AuthProxyHeader}) {
+			return api.Auth{}, errors.New("authorization cannot be set along with the other credentials")
+		}
+		log.Printf("W! The authorization option of the grafana input is deprecated, " +
+			"use api_key, service_account_token or username and password")
+		auth = legacyAuth(s.Authorization)
+	}
+	return auth, nil
+}
+
+// legacyAuth parses the deprecated authorization option, an api key with or
+// without its "Bearer " prefix, or credentials in user:pass format. The
+// password is what follows the first colon, it may hold colons itself.
+func legacyAuth(authorization string) api.Auth {
+	authorization = strings.TrimSpace(authorization)
+	if fields := strings.Fields(authorization); len(fields) == 2 && strings.EqualFold(fields[0], "Bearer") {
+		return api.Auth{Token: fields[1]}
+	}
+	if i := strings.Index(authorization, ":"); i >= 0 {
+		return api.Auth{Username: authorization[:i], Password: authorization[i+1:]}
+	}
+	return api.Auth{Token: authorization}
+}
+
+// secretOption returns value, or the content of file when value is empty,
+// so that secrets can be kept out of the config
+func secretOption(value, file string) (string, error) {
+	if value != "" || file == "" {
+		return value, nil
+	}
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
@@ -0,0 +1,112 @@
+package grafana
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vikramjakhr/grafana-dashboard-exporter/plugins/inputs/grafana/api"
+)
+
+// fetchedDashboard is a dashboard fetched by the workers, or the error
+// fetching it, along with its permissions when they are exported
+type fetchedDashboard struct {
+	dashboard *api.Dashboard
+	err       error
+
+	permissions    []api.Permission
+	permissionsErr error
+}
+
+// fetchDashboards fetches the dashboards of the search results with up to
+// max_concurrency workers, no faster than rate_limit. The dashboard of
+// results[i] is sent on the i-th channel so that they are emitted in the
+// order of the search whatever the order they are fetched in. Closing done
+// stops the workers.
+func (s *Grafana) fetchDashboards(gClient *api.GrafanaClient, results []api.SearchResp, done <-chan struct{}) []chan fetchedDashboard {
+	fetched := make([]chan fetchedDashboard, len(results))
+	for i := range fetched {
+		fetched[i] = make(chan fetchedDashboard, 1)
+	}
+
+	var tick <-chan time.Time
+	if s.RateLimit > 0 {
+		ticker := time.NewTicker(s.rateInterval())
+		tick = ticker.C
+		go func() {
+			<-done
+			ticker.Stop()
+		}()
+	}
+
+	indexes := make(chan int)
+	go func() {
+		defer close(indexes)
+		for i := range results {
+			select {
+			case indexes <- i:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for w := 0; w < s.concurrency(); w++ {
+		go func() {
+			for i := range indexes {
+				if tick != nil {
+					select {
+					case <-tick:
+					case <-done:
+						return
+					}
+				}
+				f := fetchedDashboard{}
+				f.dashboard, f.err = fetchDashboard(gClient, results[i])
+				if f.err == nil && s.DashboardPermission && results[i].Uid != "" {
+					f.permissions, f.permissionsErr = gClient.GetDashboardPermissions(results[i].Uid)
+				}
+				fetched[i] <- f
+			}
+		}()
+	}
+	return fetched
+}
+
+// fetchDashboard fetches the dashboard of the search result db, by uri on
+// grafana releases without dashboard uids
+func fetchDashboard(gClient *api.GrafanaClient, db api.SearchResp) (*api.Dashboard, error) {
+	if db.Uid != "" {
+		return gClient.GetDashboard(db.Uid)
+	}
+	return gClient.GetDashboardByUri(db.Uri)
+}
+
+// maxRateLimit is the largest rate_limit, fetching a dashboard every
+// nanosecond
+const maxRateLimit = float64(time.Second)
+
+// rateInterval returns the interval between two dashboard fetches under
+// rate_limit, which must be valid
+func (s *Grafana) rateInterval() time.Duration {
+	return time.Duration(float64(time.Second) / s.RateLimit)
+}
+
+// checkRateLimit checks that rate_limit is 0 or gives an interval of at
+// least a nanosecond between two fetches
+func (s *Grafana) checkRateLimit() error {
+	if s.RateLimit == 0 {
+		return nil
+	}
+	if !(s.RateLimit > 0 && s.RateLimit <= maxRateLimit) {
+		return fmt.Errorf("rate_limit %g must be between 0 and %g dashboards per second", s.RateLimit, maxRateLimit)
+	}
+	return nil
+}
+
+// concurrency returns the number of dashboards fetched at once
+func (s *Grafana) concurrency() int {
+	if s.MaxConcurrency < 1 {
+		return 1
+	}
+	return s.MaxConcurrency
+}
@@ -0,0 +1,115 @@
+package grafana
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/vikramjakhr/grafana-dashboard-exporter/plugins/inputs/grafana/api"
+)
+
+// generalFolder is the name the filters give to the root folder, which has
+// neither a uid nor an id
+const generalFolder = "General"
+
+// dashboardFilter selects the dashboards to export from the include and
+// exclude options. Folders are matched by title or uid.
+type dashboardFilter struct {
+	includeFolders []string
+	excludeFolders []string
+	includeTags    []string
+	excludeTags    []string
+	titlePattern   *regexp.Regexp
+	uids           []string
+}
+
+// filter returns the dashboard filter of the options
+func (s *Grafana) filter() (*dashboardFilter, error) {
+	f := &dashboardFilter{
+		includeFolders: s.IncludeFolders,
+		excludeFolders: s.ExcludeFolders,
+		includeTags:    s.IncludeTags,
+		excludeTags:    s.ExcludeTags,
+		uids:           s.UIDs,
+	}
+	if s.TitlePattern != "" {
+		re, err := regexp.Compile(s.TitlePattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid title_pattern %q: %s", s.TitlePattern, err)
+		}
+		f.titlePattern = re
+	}
+	return f, nil
+}
+
+// searchParams returns the search parameters narrowing the search down to
+// the dashboards of the filter, folders being the results of the folder
+// search. The search only ANDs tags, so it is narrowed down by a single
+// included tag only. Results must still go through match, grafana releases
+// ignore the parameters they do not know and included folders which do not
+// exist are left out.
+func (f *dashboardFilter) searchParams(folders []api.SearchResp) api.SearchParams {
+	params := api.SearchParams{DashboardUIDs: f.uids}
+	if len(f.includeTags) == 1 {
+		params.Tags = f.includeTags
+	}
+	for _, name := range f.includeFolders {
+		if name == generalFolder {
+			params.FolderIds = append(params.FolderIds, 0)
+			continue
+		}
+		for _, folder := range folders {
+			if name == folder.Title || name == folder.Uid {
+				params.FolderIds = append(params.FolderIds, folder.Id)
+			}
+		}
+	}
+	return params
+}
+
+// matchFolder reports whether the folder of title and uid is to be
+// exported, the root folder having neither
+func (f *dashboardFilter) matchFolder(title, uid string) bool {
+	if uid == "" && title == "" {
+		title = generalFolder
+	}
+	if len(f.includeFolders) > 0 && !contains(f.includeFolders, title) && !contains(f.includeFolders, uid) {
+		return false
+	}
+	return !contains(f.excludeFolders, title) && !contains(f.excludeFolders, uid)
+}
+
+// match reports whether the dashboard of the search result db is to be
+// exported
+func (f *dashboardFilter) match(db api.SearchResp) bool {
+	if len(f.uids) > 0 && !contains(f.uids, db.Uid) {
+		return false
+	}
+	if !f.matchFolder(db.FolderTitle, db.FolderUid) {
+		return false
+	}
+	if len(f.includeTags) > 0 && !containsAny(db.Tags, f.includeTags) {
+		return false
+	}
+	if containsAny(db.Tags, f.excludeTags) {
+		return false
+	}
+	return f.titlePattern == nil || f.titlePattern.MatchString(db.Title)
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAny(list []string, values []string) bool {
+	for _, v := range values {
+		if contains(list, v) {
+			return true
+		}
+	}
+	return false
+}
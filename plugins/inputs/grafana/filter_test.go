@@ -0,0 +1,88 @@
+package grafana
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vikramjakhr/grafana-dashboard-exporter/plugins/inputs/grafana/api"
+)
+
+var (
+	home    = api.SearchResp{Uid: "home", Title: "Home", Tags: []string{"overview"}}
+	latency = api.SearchResp{Uid: "lat", Title: "API Latency", Tags: []string{"prod", "api"},
+		FolderId: 12, FolderUid: "team-a", FolderTitle: "Team A"}
+	scratch = api.SearchResp{Uid: "tmp", Title: "Scratch", Tags: []string{"wip"},
+		FolderId: 13, FolderUid: "team-b", FolderTitle: "Team B"}
+)
+
+func TestFilterMatch(t *testing.T) {
+	tests := []struct {
+		name string
+		s    Grafana
+		want []string
+	}{
+		{"no filter", Grafana{}, []string{"home", "lat", "tmp"}},
+		{"folder title", Grafana{IncludeFolders: []string{"Team A"}}, []string{"lat"}},
+		{"folder uid", Grafana{IncludeFolders: []string{"team-b"}}, []string{"tmp"}},
+		{"general folder", Grafana{IncludeFolders: []string{generalFolder, "Team B"}}, []string{"home", "tmp"}},
+		{"excluded general folder", Grafana{ExcludeFolders: []string{generalFolder}}, []string{"lat", "tmp"}},
+		{"excluded folder", Grafana{IncludeFolders: []string{"Team A", "Team B"}, ExcludeFolders: []string{"team-b"}}, []string{"lat"}},
+		{"any included tag", Grafana{IncludeTags: []string{"api", "wip"}}, []string{"lat", "tmp"}},
+		{"excluded tag", Grafana{ExcludeTags: []string{"prod"}}, []string{"home", "tmp"}},
+		{"title pattern", Grafana{TitlePattern: "^(API|Home)"}, []string{"home", "lat"}},
+		{"uids", Grafana{UIDs: []string{"tmp", "gone"}}, []string{"tmp"}},
+		{"all filters", Grafana{IncludeFolders: []string{"Team A"}, IncludeTags: []string{"prod"}, TitlePattern: "Latency", UIDs: []string{"lat"}}, []string{"lat"}},
+	}
+	for _, tt := range tests {
+		f, err := tt.s.filter()
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		var got []string
+		for _, db := range []api.SearchResp{home, latency, scratch} {
+			if f.match(db) {
+				got = append(got, db.Uid)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: matched %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFilterInvalidTitlePattern(t *testing.T) {
+	s := Grafana{TitlePattern: "API("}
+	if _, err := s.filter(); err == nil {
+		t.Errorf("filter() with title_pattern %q returned no error", s.TitlePattern)
+	}
+}
+
+func TestFilterSearchParams(t *testing.T) {
+	folders := []api.SearchResp{
+		{Id: 12, Uid: "team-a", Title: "Team A", Type: api.SearchTypeDashFolder},
+		{Id: 13, Uid: "team-b", Title: "Team B", Type: api.SearchTypeDashFolder},
+	}
+	tests := []struct {
+		name string
+		s    Grafana
+		want api.SearchParams
+	}{
+		{"no filter", Grafana{}, api.SearchParams{}},
+		{"single tag", Grafana{IncludeTags: []string{"prod"}}, api.SearchParams{Tags: []string{"prod"}}},
+		// the search ANDs tags while the filter ORs them
+		{"several tags", Grafana{IncludeTags: []string{"prod", "api"}}, api.SearchParams{}},
+		{"folders", Grafana{IncludeFolders: []string{"team-b", generalFolder, "Team A", "Missing"}},
+			api.SearchParams{FolderIds: []int64{13, 0, 12}}},
+		{"uids", Grafana{UIDs: []string{"lat"}}, api.SearchParams{DashboardUIDs: []string{"lat"}}},
+	}
+	for _, tt := range tests {
+		f, err := tt.s.filter()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := f.searchParams(folders); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: searchParams() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
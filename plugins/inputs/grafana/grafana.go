@@ -2,20 +2,112 @@ package grafana
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/vikramjakhr/grafana-dashboard-exporter"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/internal"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/internal/archive"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/internal/tls"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/manifest"
 	"github.com/vikramjakhr/grafana-dashboard-exporter/plugins/inputs"
 	"github.com/vikramjakhr/grafana-dashboard-exporter/plugins/inputs/grafana/api"
 	"log"
+	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
 
 type Grafana struct {
-	Host          string `toml:"host"`
+	Host       string `toml:"host"`
+	Dashboard  bool   `toml:"dashboard"`
+	Datasource bool   `toml:"datasource"`
+	Folder     bool   `toml:"folder"`
+
+	// DashboardPermission exports the access control list of every
+	// dashboard which has one of its own
+	DashboardPermission bool `toml:"dashboard_permission"`
+
+	// Credentials, a single scheme at a time. Secrets can be read from a
+	// file instead.
+	APIKey                  string `toml:"api_key"`
+	APIKeyFile              string `toml:"api_key_file"`
+	ServiceAccountToken     string `toml:"service_account_token"`
+	ServiceAccountTokenFile string `toml:"service_account_token_file"`
+	Username                string `toml:"username"`
+	Password                string `toml:"password"`
+	PasswordFile            string `toml:"password_file"`
+	AuthProxyHeader         string `toml:"auth_proxy_header"`
+	AuthProxyUser           string `toml:"auth_proxy_user"`
+
+	// Authorization is an api key or user:pass credentials, deprecated in
+	// favor of the options above
 	Authorization string `toml:"authorization"`
-	Dashboard     bool   `toml:"dashboard"`
-	Datasource    bool   `toml:"datasource"`
+
+	// Legacy alerting
+	AlertNotification bool `toml:"alert_notification"`
+
+	// Unified alerting
+	ContactPoint         bool `toml:"contact_point"`
+	NotificationPolicy   bool `toml:"notification_policy"`
+	MuteTiming           bool `toml:"mute_timing"`
+	NotificationTemplate bool `toml:"notification_template"`
+	AlertRule            bool `toml:"alert_rule"`
+
+	// Access
+	User bool `toml:"user"`
+	Team bool `toml:"team"`
+
+	// MaxConcurrency is the number of dashboards fetched at once and
+	// RateLimit the maximum number of dashboards fetched per second
+	MaxConcurrency int     `toml:"max_concurrency"`
+	RateLimit      float64 `toml:"rate_limit"`
+
+	// Timeout bounds every request, failed requests being retried up to
+	// MaxRetries times after RetryBackoff, doubled on every retry
+	Timeout      internal.Duration `toml:"timeout"`
+	MaxRetries   int               `toml:"max_retries"`
+	RetryBackoff internal.Duration `toml:"retry_backoff"`
+
+	// SearchPageSize is the number of results of every search page
+	SearchPageSize int `toml:"search_page_size"`
+
+	// TLS options of the client, see tls.ClientConfig
+	TLSCA              string `toml:"tls_ca"`
+	TLSCert            string `toml:"tls_cert"`
+	TLSKey             string `toml:"tls_key"`
+	InsecureSkipVerify bool   `toml:"insecure_skip_verify"`
+	ServerName         string `toml:"server_name"`
+
+	// HTTPProxyURL is the proxy requests are sent through, the proxy of
+	// the environment when empty
+	HTTPProxyURL string `toml:"http_proxy_url"`
+
+	// Orgs lists the names or ids of the organizations to export, "*"
+	// exports all of them. Empty exports the org of the credentials only.
+	Orgs []string `toml:"orgs"`
+
+	// RedactSecrets is the mode datasource secrets are exported with
+	RedactSecrets  string `toml:"redact_secrets"`
+	SecretsKey     string `toml:"secrets_key"`
+	SecretsKeyFile string `toml:"secrets_key_file"`
+
+	// Dashboard filters, folders are given by title or uid
+	IncludeFolders []string `toml:"include_folders"`
+	ExcludeFolders []string `toml:"exclude_folders"`
+	IncludeTags    []string `toml:"include_tags"`
+	ExcludeTags    []string `toml:"exclude_tags"`
+	TitlePattern   string   `toml:"title_pattern"`
+	UIDs           []string `toml:"uids"`
+}
+
+// folderExport is the json written for a folder, the folder metadata along
+// with its permissions. Permissions is nil when they could not be read,
+// and left out so that it is not taken for an empty access control list.
+type folderExport struct {
+	*api.Folder
+	Permissions *[]permissionExport `json:"permissions,omitempty"`
 }
 
 func (_ *Grafana) Description() string {
@@ -24,9 +116,101 @@ func (_ *Grafana) Description() string {
 
 var sampleConfig = `
   host = "http://<host>:<port>" # required
-  authorization = "Bearer <token>" # required
+
+  ## Credentials, one of an api key, a service account token, a username
+  ## and password, or the user of an auth proxy sent in the auth proxy
+  ## header. Secrets can be read from a file instead of the config.
+  service_account_token = "$GRAFANA_TOKEN"
+  # service_account_token_file = "/etc/gde/token"
+  # api_key = ""
+  # api_key_file = ""
+  # username = "admin"
+  # password = ""
+  # password_file = "/etc/gde/password"
+  # auth_proxy_header = "X-WEBAUTH-USER"
+  # auth_proxy_user = "admin"
+  ## Deprecated, an api key or user:pass credentials
+  # authorization = ""
+
   dashboard = true # true if dashboard needs to be fetched; default true
   datasource = true # true if datasource needs to be fetched; default true
+  folder = true # true if folders needs to be fetched; default true
+  ## Permissions set on the dashboards themselves, keyed by user login and
+  ## team name. Folders are always exported with their permissions, without
+  ## them when they cannot be read.
+  # dashboard_permission = false
+
+  ## Legacy alerting notification channels, removed in grafana 11
+  # alert_notification = false
+  ## Unified alerting resources, fetched from the provisioning api
+  # contact_point = false
+  # notification_policy = false
+  # mute_timing = false
+  # notification_template = false
+  # alert_rule = false
+
+  ## Users of the org with their role, and teams with their members
+  # user = false
+  # team = false
+
+  ## Number of dashboards fetched at once, and the maximum number of
+  ## dashboards fetched per second, unlimited when 0. Dashboards are
+  ## emitted in the order of the search whatever the concurrency.
+  # max_concurrency = 1
+  # rate_limit = 0.0
+
+  ## Timeout of every request to grafana. Requests failing on a network
+  ## error or with a 429, 502, 503 or 504 status are retried up to
+  ## max_retries times, waiting retry_backoff before the first retry and
+  ## twice as long before every next one, or as long as the Retry-After
+  ## header asks for.
+  # timeout = "30s"
+  # max_retries = 3
+  # retry_backoff = "1s"
+
+  ## Number of results requested per /api/search page, searches are paged
+  ## through until every result is returned, 5000 at most
+  # search_page_size = 1000
+
+  ## TLS options, a CA to verify the grafana certificate with, a client
+  ## certificate and key for mutual TLS, and the name the certificate is
+  ## verified against when it differs from the host.
+  # tls_ca = "/etc/gde/ca.pem"
+  # tls_cert = "/etc/gde/cert.pem"
+  # tls_key = "/etc/gde/key.pem"
+  # server_name = "grafana.internal"
+  ## Use TLS but skip the verification of the grafana certificate
+  # insecure_skip_verify = false
+
+  ## Proxy the requests are sent through. By default the HTTP_PROXY,
+  ## HTTPS_PROXY and NO_PROXY environment variables apply.
+  # http_proxy_url = "http://proxy.internal:3128"
+
+  ## Organizations to export by name or id, "*" exports all of them.
+  ## Requires the credentials of a grafana admin, username and password or
+  ## auth proxy user. By default only the org of the credentials is exported.
+  # orgs = ["*"]
+
+  ## How the datasource password, basicAuthPassword and secureJsonData are
+  ## exported: "strip" removes them, "placeholder" replaces them with
+  ## "<redacted>" and "encrypt" encrypts them with the secrets key so that
+  ## "gde restore" can put them back. By default they are kept as is.
+  # redact_secrets = "strip"
+  ## Key the secrets are encrypted with, or a file containing it
+  # secrets_key = "$GDE_SECRETS_KEY"
+  # secrets_key_file = ""
+
+  ## Dashboards to export, by default all of them. Folders are given by
+  ## title or uid, "General" being the root folder, and also filter the
+  ## exported folders. A dashboard is exported when it has any of the
+  ## included tags, none of the excluded ones and its title matches the
+  ## regular expression.
+  # include_folders = ["Production"]
+  # exclude_folders = ["Scratch"]
+  # include_tags = ["prod"]
+  # exclude_tags = ["wip"]
+  # title_pattern = "^(API|Frontend)"
+  # uids = ["000000012"]
 `
 
 func (_ *Grafana) SampleConfig() string {
@@ -34,68 +218,340 @@ func (_ *Grafana) SampleConfig() string {
 }
 
 func (s *Grafana) Process(acc gde.Accumulator) error {
-	if s.Datasource || s.Dashboard {
-		gClient, err := api.NewGrafanaClient(s.Authorization, s.Host)
+	if s.enabled() {
+		gClient, err := s.client()
 		if err != nil {
 			return err
 		}
 
-		org, err := gClient.GetCurrentOrg()
+		tym := time.Now()
+
+		if len(s.Orgs) == 0 {
+			org, err := gClient.GetCurrentOrg()
+			if err != nil {
+				return err
+			}
+			return s.processOrg(acc, gClient, org, tym)
+		}
+
+		orgs, err := s.selectOrgs(gClient)
 		if err != nil {
 			return err
 		}
+		for i := range orgs {
+			org := &orgs[i]
+			err := s.processOrg(acc, gClient.WithOrg(org.Id), org, tym)
+			if err != nil {
+				acc.AddError(fmt.Errorf("unable to export org %s. %v", org.Name, err))
+			}
+		}
 
-		tym := time.Now()
+	} else {
+		log.Printf("E! Error in grafana input plugin. Atleast one of the object types must be true.")
+	}
+	return nil
+}
+
+// client returns a client of the configured grafana host
+func (s *Grafana) client() (*api.GrafanaClient, error) {
+	auth, err := s.auth()
+	if err != nil {
+		return nil, err
+	}
+	gClient, err := api.NewGrafanaClient(auth, s.Host)
+	if err != nil {
+		return nil, err
+	}
+	gClient.Timeout = s.Timeout.Duration
+	gClient.MaxRetries = s.MaxRetries
+	gClient.RetryBackoff = s.RetryBackoff.Duration
+	if err := s.checkRateLimit(); err != nil {
+		return nil, err
+	}
+	if s.SearchPageSize > api.MaxSearchPageSize {
+		return nil, fmt.Errorf("search_page_size %d is larger than the %d results grafana returns per page",
+			s.SearchPageSize, api.MaxSearchPageSize)
+	}
+	gClient.SearchPageSize = s.SearchPageSize
+
+	transport, err := s.transport()
+	if err != nil {
+		return nil, err
+	}
+	gClient.Transport = transport
+	return gClient, nil
+}
+
+// transport returns the transport of the client, with the TLS and proxy
+// options
+func (s *Grafana) transport() (*http.Transport, error) {
+	tlsConfig := &tls.ClientConfig{
+		TLSCA:              s.TLSCA,
+		TLSCert:            s.TLSCert,
+		TLSKey:             s.TLSKey,
+		InsecureSkipVerify: s.InsecureSkipVerify,
+		ServerName:         s.ServerName,
+	}
+	config, err := tlsConfig.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if s.HTTPProxyURL != "" {
+		u, err := url.Parse(s.HTTPProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid http_proxy_url %q: %s", s.HTTPProxyURL, err)
+		}
+		proxy = http.ProxyURL(u)
+	}
+
+	return &http.Transport{
+		Proxy:               proxy,
+		TLSClientConfig:     config,
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConnsPerHost: s.concurrency(),
+	}, nil
+}
+
+// enabled reports whether at least one object type is to be exported
+func (s *Grafana) enabled() bool {
+	return s.Datasource || s.Dashboard || s.Folder || s.alerting() || s.User || s.Team
+}
 
-		dir := fmt.Sprintf("%s@%s",
-			strings.Replace(org.Name, " ", "", -1),
-			tym.Format("2006-January-2T15:04:05"))
+// selectOrgs returns the organizations matching the orgs option
+func (s *Grafana) selectOrgs(gClient *api.GrafanaClient) ([]api.Org, error) {
+	if !gClient.UserAuth() {
+		return nil, errors.New("orgs requires the credentials of a grafana admin, username and password or auth proxy user")
+	}
+
+	all, err := gClient.GetOrgs()
+	if err != nil {
+		return nil, err
+	}
+
+	var orgs []api.Org
+	for _, org := range all {
+		for _, o := range s.Orgs {
+			if o == "*" || o == org.Name || o == strconv.FormatInt(org.Id, 10) {
+				orgs = append(orgs, org)
+				break
+			}
+		}
+	}
+	if len(orgs) == 0 {
+		log.Printf("W! No grafana org matches %v", s.Orgs)
+	}
+	return orgs, nil
+}
+
+// orgDir returns the name of the org in its <Org>@<timestamp> run
+// directories, without spaces and sanitized like the names of the objects
+func orgDir(name string) string {
+	return archive.Sanitize(strings.Replace(name, " ", "", -1))
+}
+
+// processOrg exports the objects of a single org under its own
+// <Org>@<timestamp> directory
+func (s *Grafana) processOrg(orgAcc gde.Accumulator, gClient *api.GrafanaClient, org *api.Org, tym time.Time) error {
+	started := time.Now()
+	acc := &runAccumulator{Accumulator: orgAcc}
+	filter, err := s.filter()
+	if err != nil {
+		return err
+	}
+	dir := fmt.Sprintf("%s@%s", orgDir(org.Name), tym.Format(internal.RunTimeLayout))
 
-		if s.Datasource {
-			dSources, err := gClient.GetDataSources()
+	if s.Datasource {
+		dSources, err := gClient.GetDataSources()
+		if err != nil {
+			return err
+		}
+
+		for _, ds := range *dSources {
+			if err := s.redactSecrets(&ds); err != nil {
+				return err
+			}
+			byts, err := json.Marshal(ds)
+			if err != nil {
+				return err
+			}
+			acc.AddOutput(dir, gde.TypeDatasource, gde.ActionCreate, ds.Name, "", byts, gde.Metadata{
+				gde.MetaUID:   ds.Uid,
+				gde.MetaOrgID: org.Id,
+			})
+		}
+	}
+
+	var folders []api.SearchResp
+	if s.Folder || len(s.IncludeFolders) > 0 {
+		results, err := gClient.Search(api.SearchTypeDashFolder, "")
+		if err != nil {
+			return err
+		}
+		folders = *results
+	}
+
+	if s.Folder {
+		for _, f := range folders {
+			if !filter.matchFolder(f.Title, f.Uid) {
+				continue
+			}
+			folder, err := gClient.GetFolder(f.Uid)
 			if err != nil {
 				return err
 			}
+			if folder == nil {
+				continue
+			}
+			// the folder is still exported without its permissions when
+			// they cannot be read, e.g. without admin rights on it
+			export := folderExport{Folder: folder}
+			if permissions, err := gClient.GetFolderPermissions(f.Uid); err != nil {
+				acc.AddError(fmt.Errorf("unable to export the permissions of folder %s (%s). %v", folder.Title, folder.Uid, err))
+			} else {
+				exported := exportPermissions(permissions)
+				export.Permissions = &exported
+			}
+			byts, err := json.Marshal(export)
+			if err != nil {
+				return err
+			}
+			acc.AddOutput(dir, gde.TypeFolder, gde.ActionCreate, folder.Title, "", byts, gde.Metadata{
+				gde.MetaUID:     folder.Uid,
+				gde.MetaOrgID:   org.Id,
+				gde.MetaVersion: folder.Version,
+				gde.MetaURL:     folder.Url,
+			})
+		}
+	}
+
+	var dashboards *manifest.Counts
+	if s.Dashboard {
+		results, err := gClient.SearchWith(api.SearchTypeDashDB, "", filter.searchParams(folders))
+		if err != nil {
+			return err
+		}
 
-			for _, ds := range *dSources {
-				byts, err := json.Marshal(ds)
-				if err != nil {
-					return err
-				}
-				acc.AddOutput(dir, gde.TypeDatasource, gde.ActionCreate, ds.Name, byts)
+		dashboards = &manifest.Counts{Discovered: len(*results)}
+		var matched []api.SearchResp
+		for _, db := range *results {
+			if !filter.match(db) {
+				log.Printf("D! Skipping dashboard %s, filtered out", db.Title)
+				dashboards.Filtered++
+				continue
 			}
+			matched = append(matched, db)
 		}
 
-		if s.Dashboard {
-			results, err := gClient.Search(api.SearchTypeDashDB, "")
+		done := make(chan struct{})
+		defer close(done)
+		fetched := s.fetchDashboards(gClient, matched, done)
+		for i, db := range matched {
+			f := <-fetched[i]
+			if f.err != nil {
+				// a single dashboard failing does not fail the whole run
+				acc.AddError(fmt.Errorf("unable to export dashboard %s (%s). %v", db.Title, db.Uid, f.err))
+				dashboards.Failed++
+				continue
+			}
+			// the meta is written along with the model, as returned by
+			// grafana, the model alone being restored
+			dashboard := f.dashboard
+			byts, err := json.Marshal(dashboard)
 			if err != nil {
 				return err
 			}
+			name, _ := dashboard.Model["title"].(string)
+			if name == "" {
+				// models without a title are named after their uid
+				name = db.Uid
+			}
+			folder := dashboard.Meta.FolderTitle
+			if dashboard.Meta.FolderId == 0 && dashboard.Meta.FolderUid == "" {
+				// dashboards in the General folder are not placed in a folder
+				folder = ""
+			}
+			acc.AddOutput(dir, gde.TypeDashboard, gde.ActionCreate, name, folder, byts, gde.Metadata{
+				gde.MetaUID:       db.Uid,
+				gde.MetaOrgID:     org.Id,
+				gde.MetaVersion:   dashboard.Meta.Version,
+				gde.MetaFolderUID: dashboard.Meta.FolderUid,
+				gde.MetaTags:      db.Tags,
+				gde.MetaURL:       dashboard.Meta.URL,
+			})
+			dashboards.Exported++
 
-			for _, db := range *results {
-				dashboard, err := gClient.GetDashboard(db.Uri)
-				if err != nil {
-					return err
-				}
-				byts, err := json.Marshal(dashboard.Model)
-				if err != nil {
-					return err
-				}
-				name := dashboard.Model["title"].(string)
-				acc.AddOutput(dir, gde.TypeDashboard, gde.ActionCreate, name, byts)
+			if f.permissionsErr != nil {
+				acc.AddError(fmt.Errorf("unable to export the permissions of dashboard %s (%s). %v", db.Title, db.Uid, f.permissionsErr))
+				continue
 			}
+			if !s.DashboardPermission || db.Uid == "" {
+				continue
+			}
+			// an empty list is written as well, it restores an access
+			// control list which was emptied on purpose
+			byts, err = json.Marshal(dashboardPermissionsExport{db.Uid, name, exportPermissions(f.permissions)})
+			if err != nil {
+				return err
+			}
+			acc.AddOutput(dir, gde.TypeDashboardPermission, gde.ActionCreate, name, folder, byts, gde.Metadata{
+				gde.MetaUID:       db.Uid,
+				gde.MetaOrgID:     org.Id,
+				gde.MetaFolderUID: dashboard.Meta.FolderUid,
+			})
 		}
+		log.Printf("I! Exported %d of the %d dashboards discovered in org %s, %d filtered out and %d failed",
+			dashboards.Exported, dashboards.Discovered, org.Name, dashboards.Filtered, dashboards.Failed)
+	}
 
-		acc.AddOutput(dir, "", gde.ActionFinish, "", nil)
+	if s.alerting() {
+		if err := s.processAlerting(acc, gClient, dir, org); err != nil {
+			return err
+		}
+	}
 
+	if s.User || s.Team {
+		if err := s.processAccess(acc, gClient, dir, org); err != nil {
+			return err
+		}
+	}
+
+	// what the outputs cannot know about the run goes to its manifest
+	run := manifest.Manifest{
+		Grafana:    manifest.Grafana{Host: s.Host},
+		Started:    started,
+		Finished:   time.Now(),
+		Dashboards: dashboards,
+		Failed:     acc.failed,
+	}
+	if health, err := gClient.GetHealth(); err != nil {
+		log.Printf("W! Unable to get the grafana version from %s: %s", s.Host, err)
 	} else {
-		log.Printf("E! Error in grafana input plugin. Atleast one of Org, Datasource and Dashboard must be true.")
+		run.Grafana.Version = health.Version
+		run.Grafana.Commit = health.Commit
+	}
+	byts, err := json.Marshal(run)
+	if err != nil {
+		return err
 	}
+	counts := acc.counts
+	if counts == nil {
+		counts = make(map[string]int64)
+	}
+	acc.AddOutput(dir, "", gde.ActionFinish, "", "", byts, gde.Metadata{
+		gde.MetaOrgID:  org.Id,
+		gde.MetaCounts: counts,
+	})
 	return nil
 }
 
 func init() {
 	inputs.Add("grafana", func() gde.Input {
-		return &Grafana{}
+		return &Grafana{
+			Timeout:      internal.Duration{Duration: 30 * time.Second},
+			MaxRetries:   3,
+			RetryBackoff: internal.Duration{Duration: time.Second},
+		}
 	})
 }
@@ -0,0 +1,127 @@
+package grafana
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/vikramjakhr/grafana-dashboard-exporter/backup"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/plugins/inputs/grafana/api"
+)
+
+// permissionExport is an entry of an access control list, keyed by user
+// login or team name as ids differ between instances. The json keys are
+// the ones of grafana, so that the permissions of backups holding the
+// entries as returned by grafana are read as well.
+type permissionExport struct {
+	UserLogin      string `json:"userLogin,omitempty"`
+	Team           string `json:"team,omitempty"`
+	Role           string `json:"role,omitempty"`
+	Permission     int64  `json:"permission"`
+	PermissionName string `json:"permissionName,omitempty"`
+}
+
+// dashboardPermissionsExport is the json written for the access control
+// list of a dashboard
+type dashboardPermissionsExport struct {
+	Uid         string             `json:"uid"`
+	Title       string             `json:"title"`
+	Permissions []permissionExport `json:"permissions"`
+}
+
+// exportPermissions returns the entries of permissions set on the object
+// itself, the entries a dashboard inherits from its folder being restored
+// along with the folder
+func exportPermissions(permissions []api.Permission) []permissionExport {
+	exported := make([]permissionExport, 0, len(permissions))
+	for _, p := range permissions {
+		if p.Inherited {
+			continue
+		}
+		exported = append(exported, permissionExport{
+			UserLogin:      p.UserLogin,
+			Team:           p.Team,
+			Role:           p.Role,
+			Permission:     p.Permission,
+			PermissionName: p.PermissionName,
+		})
+	}
+	return exported
+}
+
+// principals resolves the logins and team names of exported permissions to
+// the ids of the instance restored to, loaded on first use
+type principals struct {
+	gClient *api.GrafanaClient
+	users   map[string]int64
+	teams   map[string]int64
+}
+
+func (p *principals) load() error {
+	if p.users != nil {
+		return nil
+	}
+	users, err := p.gClient.GetOrgUsers()
+	if err != nil {
+		return err
+	}
+	teams, err := p.gClient.GetTeams()
+	if err != nil {
+		return err
+	}
+	p.users = make(map[string]int64, len(users))
+	for _, u := range users {
+		p.users[u.Login] = u.UserId
+	}
+	p.teams = make(map[string]int64, len(teams))
+	for _, t := range teams {
+		p.teams[t.Name] = t.Id
+	}
+	return nil
+}
+
+// items returns the permissions of object as the items of its access
+// control list. Entries of users or teams missing from the instance are
+// left out with a warning, leaving the object more restricted rather than
+// exposed.
+func (p *principals) items(object string, permissions []permissionExport) ([]api.PermissionItem, error) {
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+	items := make([]api.PermissionItem, 0, len(permissions))
+	for _, perm := range permissions {
+		item := api.PermissionItem{Role: perm.Role, Permission: perm.Permission}
+		switch {
+		case perm.UserLogin != "":
+			id, ok := p.users[perm.UserLogin]
+			if !ok {
+				log.Printf("W! User %s granted access to %s is not a member of the org, skipping", perm.UserLogin, object)
+				continue
+			}
+			item.UserId = id
+		case perm.Team != "":
+			id, ok := p.teams[perm.Team]
+			if !ok {
+				log.Printf("W! Team %s granted access to %s does not exist, skipping", perm.Team, object)
+				continue
+			}
+			item.TeamId = id
+		case perm.Role == "":
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func restoreDashboardPermissions(gClient *api.GrafanaClient, o backup.Object, p *principals) error {
+	d := &dashboardPermissionsExport{}
+	if err := json.Unmarshal(o.Content, d); err != nil {
+		return err
+	}
+	items, err := p.items("dashboard "+d.Title, d.Permissions)
+	if err != nil {
+		return err
+	}
+	log.Printf("D! Setting the permissions of dashboard %s", d.Title)
+	return gClient.UpdateDashboardPermissions(d.Uid, items)
+}
@@ -0,0 +1,287 @@
+package grafana
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/vikramjakhr/grafana-dashboard-exporter"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/backup"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/plugins/inputs/grafana/api"
+)
+
+// Restore pushes the datasources, folders and dashboards of the backup found
+// at backupPath to the configured grafana host. Existing datasources are updated in
+// place, missing folders are created and existing dashboards are overwritten.
+// Folder and dashboard permissions are set again for the users and teams of
+// the same login and name.
+func (s *Grafana) Restore(backupPath string) error {
+	b, err := backup.Open(backupPath)
+	if err != nil {
+		return err
+	}
+
+	gClient, err := s.client()
+	if err != nil {
+		return err
+	}
+
+	if len(s.Orgs) > 0 {
+		org, err := s.backupOrg(gClient, b.Dir)
+		if err != nil {
+			return err
+		}
+		gClient = gClient.WithOrg(org.Id)
+	}
+
+	log.Printf("I! Restoring %s to %s", b.Dir, s.Host)
+
+	restored, failed := 0, 0
+	for _, o := range b.ObjectsOf(gde.TypeDatasource) {
+		if err := s.restoreDataSource(gClient, o); err != nil {
+			log.Printf("E! Unable to restore datasource %s. %v", o.Path, err)
+			failed++
+			continue
+		}
+		restored++
+	}
+
+	folders := newRestoredFolders(b.Manifest != nil)
+	acl := &principals{gClient: gClient}
+	for _, o := range b.ObjectsOf(gde.TypeFolder) {
+		folder, err := restoreFolder(gClient, o, acl)
+		if err != nil {
+			log.Printf("E! Unable to restore folder %s. %v", o.Path, err)
+			failed++
+			continue
+		}
+		restored++
+		folders.add(folder)
+	}
+
+	for _, o := range b.ObjectsOf(gde.TypeDashboard) {
+		dashboard, meta, err := readDashboard(o)
+		if err != nil {
+			log.Printf("E! Unable to restore dashboard %s. %v", o.Path, err)
+			failed++
+			continue
+		}
+		if err := restoreDashboard(gClient, dashboard.Model, folders.of(o, meta)); err != nil {
+			log.Printf("E! Unable to restore dashboard %s. %v", o.Path, err)
+			failed++
+			continue
+		}
+		restored++
+	}
+
+	for _, o := range b.ObjectsOf(gde.TypeDashboardPermission) {
+		if err := restoreDashboardPermissions(gClient, o, acl); err != nil {
+			log.Printf("E! Unable to restore dashboard permissions %s. %v", o.Path, err)
+			failed++
+			continue
+		}
+		restored++
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d object(s) of %s could not be restored", failed, b.Dir)
+	}
+	if restored == 0 {
+		return fmt.Errorf("nothing to restore in %s, it holds no datasource, folder or dashboard", b.Dir)
+	}
+	log.Printf("I! Restored %d object(s) of %s", restored, b.Dir)
+	return nil
+}
+
+// backupOrg returns the org among the configured orgs the backup directory
+// was exported from
+func (s *Grafana) backupOrg(gClient *api.GrafanaClient, dir string) (*api.Org, error) {
+	orgs, err := s.selectOrgs(gClient)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.SplitN(dir, "@", 2)[0]
+	for i := range orgs {
+		// backups written before org names were sanitized only had their
+		// spaces removed
+		if orgDir(orgs[i].Name) == name || strings.Replace(orgs[i].Name, " ", "", -1) == name {
+			return &orgs[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no configured org matches backup %s", dir)
+}
+
+// restoreDataSource pushes the datasource json as is, so that the settings
+// api.DataSource does not know about are kept, only the ids and the
+// secrets being replaced
+func (s *Grafana) restoreDataSource(gClient *api.GrafanaClient, o backup.Object) error {
+	model := make(map[string]interface{})
+	if err := json.Unmarshal(o.Content, &model); err != nil {
+		return err
+	}
+	ds := &api.DataSource{}
+	if err := json.Unmarshal(o.Content, ds); err != nil {
+		return err
+	}
+	if err := s.restoreSecrets(ds); err != nil {
+		return err
+	}
+	setOrDelete(model, "password", ds.Password)
+	setOrDelete(model, "basicAuthPassword", ds.BasicAuthPassword)
+	if len(ds.SecureJSONData) > 0 {
+		model["secureJsonData"] = ds.SecureJSONData
+	} else {
+		delete(model, "secureJsonData")
+	}
+	// grafana only accepts the values of secure fields, and rejects
+	// updates whose version is behind its own
+	delete(model, "secureJsonFields")
+	delete(model, "version")
+
+	existing, err := gClient.GetDataSourceByName(ds.Name)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		model["id"] = existing.Id
+		model["orgId"] = existing.OrgId
+		log.Printf("D! Updating datasource %s", ds.Name)
+		return gClient.UpdateDataSource(existing.Id, model)
+	}
+
+	// ids are instance specific, let grafana assign new ones
+	delete(model, "id")
+	delete(model, "orgId")
+	log.Printf("D! Creating datasource %s", ds.Name)
+	_, err = gClient.NewDataSource(model)
+	return err
+}
+
+// setOrDelete sets key of model to value, or removes it when value is empty
+func setOrDelete(model map[string]interface{}, key, value string) {
+	if value == "" {
+		delete(model, key)
+		return
+	}
+	model[key] = value
+}
+
+func restoreFolder(gClient *api.GrafanaClient, o backup.Object, p *principals) (*api.Folder, error) {
+	f := &folderExport{}
+	if err := json.Unmarshal(o.Content, f); err != nil {
+		return nil, err
+	}
+	if f.Folder == nil || f.Uid == "" {
+		return nil, fmt.Errorf("no folder uid found")
+	}
+
+	folder, err := gClient.GetFolder(f.Uid)
+	if err != nil {
+		return nil, err
+	}
+	if folder == nil {
+		log.Printf("D! Creating folder %s", f.Title)
+		if folder, err = gClient.NewFolder(f.Uid, f.Title); err != nil {
+			return nil, err
+		}
+	}
+
+	// backups of folders without permissions leave the permissions of
+	// grafana as they are, an empty list empties them
+	if f.Permissions != nil {
+		items, err := p.items("folder "+f.Title, *f.Permissions)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("D! Setting the permissions of folder %s", f.Title)
+		if err := gClient.UpdateFolderPermissions(folder.Uid, items); err != nil {
+			return nil, err
+		}
+	}
+	return folder, nil
+}
+
+// restoredFolders are the folders restored so far, keyed by uid, and by
+// title or by the directory name their dashboards are written to for the
+// dashboards of older backups which do not carry the uid of their folder
+type restoredFolders struct {
+	manifest bool
+	byUID    map[string]*api.Folder
+	byTitle  map[string]*api.Folder
+}
+
+// newRestoredFolders returns the folders of a backup, with a manifest or
+// not
+func newRestoredFolders(manifest bool) *restoredFolders {
+	return &restoredFolders{
+		manifest: manifest,
+		byUID:    make(map[string]*api.Folder),
+		byTitle:  make(map[string]*api.Folder),
+	}
+}
+
+func (f *restoredFolders) add(folder *api.Folder) {
+	f.byUID[folder.Uid] = folder
+	if f.manifest {
+		f.byTitle[folder.Title] = folder
+	} else {
+		f.byTitle[strings.Replace(folder.Title, " ", "", -1)] = folder
+	}
+}
+
+// of returns the folder dashboard o is restored to, nil for the General
+// folder. meta is the meta of the dashboard, nil for older backups.
+func (f *restoredFolders) of(o backup.Object, meta *api.DashboardMeta) *api.Folder {
+	var folder *api.Folder
+	name := ""
+	if meta != nil {
+		name = meta.FolderUid
+		folder = f.byUID[name]
+	} else {
+		name = o.Folder
+		if !f.manifest && path.Dir(o.Path) != "." {
+			name = path.Dir(o.Path)
+		}
+		folder = f.byTitle[name]
+	}
+	if name != "" && folder == nil {
+		log.Printf("W! Folder %s of dashboard %s is not part of the backup, "+
+			"restoring it to the General folder", name, o.Path)
+	}
+	return folder
+}
+
+// readDashboard reads the dashboard of o and its meta. Dashboards are
+// exported along with their meta, older backups only hold the model and
+// have no meta.
+func readDashboard(o backup.Object) (*api.Dashboard, *api.DashboardMeta, error) {
+	dashboard := &api.Dashboard{}
+	if err := json.Unmarshal(o.Content, dashboard); err != nil {
+		return nil, nil, err
+	}
+	if dashboard.Model != nil {
+		return dashboard, &dashboard.Meta, nil
+	}
+	if err := json.Unmarshal(o.Content, &dashboard.Model); err != nil {
+		return nil, nil, err
+	}
+	return dashboard, nil, nil
+}
+
+func restoreDashboard(gClient *api.GrafanaClient, model map[string]interface{}, folder *api.Folder) error {
+	// dashboards are matched on uid, or title if there is none
+	delete(model, "id")
+	save := &api.DashboardSaveRequest{
+		Model:     model,
+		Overwrite: true,
+		Message:   "Restored by gde",
+	}
+	if folder != nil {
+		save.FolderId = folder.Id
+		save.FolderUid = folder.Uid
+	}
+	log.Printf("D! Saving dashboard %v", model["title"])
+	return gClient.SaveDashboard(save)
+}
@@ -0,0 +1,28 @@
+package grafana
+
+import (
+	"github.com/vikramjakhr/grafana-dashboard-exporter"
+)
+
+// runAccumulator is the accumulator of the run of an org, counting the
+// objects emitted and the errors reported along the run for its manifest
+type runAccumulator struct {
+	gde.Accumulator
+	counts map[string]int64
+	failed int
+}
+
+func (r *runAccumulator) AddOutput(dir string, valueType gde.ValueType, action gde.Action, title string, folder string, content []byte, metadata gde.Metadata) {
+	if action == gde.ActionCreate {
+		if r.counts == nil {
+			r.counts = make(map[string]int64)
+		}
+		r.counts[string(valueType)]++
+	}
+	r.Accumulator.AddOutput(dir, valueType, action, title, folder, content, metadata)
+}
+
+func (r *runAccumulator) AddError(err error) {
+	r.failed++
+	r.Accumulator.AddError(err)
+}
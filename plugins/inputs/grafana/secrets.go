@@ -0,0 +1,128 @@
+package grafana
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/vikramjakhr/grafana-dashboard-exporter/internal/secret"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/plugins/inputs/grafana/api"
+)
+
+// Possible values of the redact_secrets option
+const (
+	secretsKeep        = ""
+	secretsStrip       = "strip"
+	secretsPlaceholder = "placeholder"
+	secretsEncrypt     = "encrypt"
+)
+
+// secretPlaceholder replaces the datasource secrets in placeholder mode
+const secretPlaceholder = "<redacted>"
+
+var errNoSecretsKey = errors.New("secrets_key or secrets_key_file is required to encrypt or decrypt datasource secrets")
+
+// redactSecrets strips, placeholders or encrypts the secrets of ds
+// according to the redact_secrets option
+func (s *Grafana) redactSecrets(ds *api.DataSource) error {
+	switch strings.ToLower(s.RedactSecrets) {
+	case secretsKeep:
+		return nil
+	case secretsStrip:
+		ds.Password = ""
+		ds.BasicAuthPassword = ""
+		ds.SecureJSONData = nil
+		ds.SecureJSONFields = nil
+		return nil
+	case secretsPlaceholder:
+		return eachSecret(ds, func(value string) (string, error) {
+			return secretPlaceholder, nil
+		})
+	case secretsEncrypt:
+		key, err := secretOption(s.SecretsKey, s.SecretsKeyFile)
+		if err != nil {
+			return err
+		}
+		if key == "" {
+			return errNoSecretsKey
+		}
+		return eachSecret(ds, func(value string) (string, error) {
+			if value == "" {
+				return "", nil
+			}
+			return secret.EncryptString(key, value)
+		})
+	}
+	return fmt.Errorf("unknown redact_secrets mode %q, expected %q, %q or %q",
+		s.RedactSecrets, secretsStrip, secretsPlaceholder, secretsEncrypt)
+}
+
+// restoreSecrets decrypts the secrets of ds encrypted on export and drops
+// the placeholders, which would otherwise overwrite the actual secrets
+func (s *Grafana) restoreSecrets(ds *api.DataSource) error {
+	var key string
+	return eachSecret(ds, func(value string) (string, error) {
+		switch {
+		case value == secretPlaceholder:
+			log.Printf("W! Secrets of datasource %s were redacted on export, "+
+				"they must be set again", ds.Name)
+			return "", nil
+		case secret.IsEncrypted(value):
+			if key == "" {
+				k, err := secretOption(s.SecretsKey, s.SecretsKeyFile)
+				if err != nil {
+					return "", err
+				}
+				if k == "" {
+					return "", errNoSecretsKey
+				}
+				key = k
+			}
+			return secret.DecryptString(key, value)
+		}
+		return value, nil
+	})
+}
+
+// eachSecret replaces every secret of ds by the result of fn. The secure
+// json fields without value, which grafana never returns, are passed as
+// empty strings.
+func eachSecret(ds *api.DataSource, fn func(value string) (string, error)) error {
+	var err error
+	if ds.Password != "" {
+		if ds.Password, err = fn(ds.Password); err != nil {
+			return err
+		}
+	}
+	if ds.BasicAuthPassword != "" {
+		if ds.BasicAuthPassword, err = fn(ds.BasicAuthPassword); err != nil {
+			return err
+		}
+	}
+
+	secure := make(api.SecureJSONData)
+	for name, value := range ds.SecureJSONData {
+		secure[name] = value
+	}
+	for name, set := range ds.SecureJSONFields {
+		if _, ok := secure[name]; set && !ok {
+			secure[name] = ""
+		}
+	}
+	for name, value := range secure {
+		if value, err = fn(value); err != nil {
+			return err
+		}
+		if value == "" {
+			delete(secure, name)
+			continue
+		}
+		secure[name] = value
+	}
+	if len(secure) == 0 {
+		secure = nil
+	}
+	ds.SecureJSONData = secure
+	return nil
+}
@@ -2,5 +2,6 @@ package all
 
 import (
 	_ "github.com/vikramjakhr/grafana-dashboard-exporter/plugins/outputs/file"
+	_ "github.com/vikramjakhr/grafana-dashboard-exporter/plugins/outputs/git"
 	_ "github.com/vikramjakhr/grafana-dashboard-exporter/plugins/outputs/s3"
 )
@@ -0,0 +1,172 @@
+package outputs
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/vikramjakhr/grafana-dashboard-exporter"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/internal"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/internal/archive"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/manifest"
+)
+
+// Run is a finished backup run, the objects exported by an input for an
+// org at one point in time
+type Run struct {
+	// Dir is the run directory, ie, "MainOrg@2019-April-7T10:00:00"
+	Dir string
+	// Files are the objects of the run, named after their path relative
+	// to Dir, followed by the manifest
+	Files []archive.Entry
+	// Metrics are the metrics the objects were made of, in the same order
+	Metrics []gde.Metric
+	// Manifest lists the objects of the run
+	Manifest *manifest.Manifest
+
+	names map[string]bool
+	// updated is when the run last received an object
+	updated time.Time
+}
+
+// Org returns the org of the run, the part of Dir before the timestamp
+func (r *Run) Org() string {
+	return strings.SplitN(r.Dir, "@", 2)[0]
+}
+
+// Sink writes finished runs to their destination
+type Sink interface {
+	WriteRun(run *Run) error
+}
+
+// Assembler collects the objects of the runs in progress and hands every
+// run to its sink once it finishes. Outputs writing whole runs embed one
+// and pass every metric to Write. Runs are told apart by their directory
+// only, several inputs may export orgs of the same name at once.
+type Assembler struct {
+	// Naming names the files of the objects, set by the output from its
+	// naming option
+	Naming archive.Naming
+
+	sink Sink
+	runs map[string]*Run
+}
+
+// NewAssembler returns an assembler handing the finished runs to sink
+func NewAssembler(sink Sink) *Assembler {
+	return &Assembler{
+		sink: sink,
+		runs: make(map[string]*Run),
+	}
+}
+
+// Write holds ActionCreate metrics back in the run of their directory and
+// hands the run to the sink on ActionFinish
+func (a *Assembler) Write(metric gde.Metric) error {
+	switch metric.Action() {
+	case gde.ActionCreate:
+		a.run(metric.Dir()).add(metric, a.Naming)
+	case gde.ActionFinish:
+		run := a.run(metric.Dir())
+		delete(a.runs, metric.Dir())
+		if err := run.finish(metric); err != nil {
+			return err
+		}
+		return a.sink.WriteRun(run)
+	}
+	return nil
+}
+
+// staleRun is how long a run may go without any object before it is
+// considered aborted
+const staleRun = time.Hour
+
+// run returns the run of dir, started on its first object
+func (a *Assembler) run(dir string) *Run {
+	now := time.Now()
+	if run, ok := a.runs[dir]; ok {
+		run.updated = now
+		return run
+	}
+	// runs which never finished were aborted, drop what they held back
+	for d, r := range a.runs {
+		if now.Sub(r.updated) > staleRun {
+			log.Printf("W! Dropping run %s, it never finished", d)
+			delete(a.runs, d)
+		}
+	}
+	run := &Run{Dir: dir, updated: now}
+	a.runs[dir] = run
+	return run
+}
+
+func (r *Run) add(metric gde.Metric, naming archive.Naming) {
+	r.Files = append(r.Files, archive.Entry{
+		Name:    r.uniqueName(naming.Path(metric), metric.UID()),
+		Content: metric.Content(),
+	})
+	r.Metrics = append(r.Metrics, metric)
+}
+
+// uniqueName returns name, or name suffixed with the uid of the object or a
+// counter when another object of the run already has that name
+func (r *Run) uniqueName(name, uid string) string {
+	if r.names == nil {
+		r.names = make(map[string]bool)
+	}
+	unique := name
+	base := strings.TrimSuffix(name, ".json")
+	if r.names[unique] && uid != "" {
+		unique = base + "-" + archive.Sanitize(uid) + ".json"
+	}
+	for i := 2; r.names[unique]; i++ {
+		unique = fmt.Sprintf("%s-%d.json", base, i)
+	}
+	if unique != name {
+		log.Printf("D! %s is already used in run %s, writing %s instead", name, r.Dir, unique)
+	}
+	r.names[unique] = true
+	return unique
+}
+
+// finish builds the manifest of the run out of its objects and what the
+// input tells about the run in the content of the finish metric
+func (r *Run) finish(metric gde.Metric) error {
+	m := &manifest.Manifest{}
+	if len(metric.Content()) > 0 {
+		var err error
+		if m, err = manifest.Parse(metric.Content()); err != nil {
+			return err
+		}
+	}
+	m.Dir = r.Dir
+	m.GDEVersion = internal.Version()
+	if m.Finished.IsZero() {
+		m.Finished = time.Now()
+	}
+	m.Objects = make([]manifest.Object, 0, len(r.Metrics))
+	for i, metric := range r.Metrics {
+		m.Objects = append(m.Objects, manifest.NewObject(metric, r.Files[i].Name))
+	}
+	m.Count = len(m.Objects)
+	if counts := metric.Metadata().Counts(gde.MetaCounts); counts != nil {
+		// objects lost before reaching the output are not listed, the
+		// manifest then fails to verify
+		m.Count = 0
+		m.CountByType = make(map[gde.ValueType]int, len(counts))
+		for t, n := range counts {
+			m.CountByType[gde.ValueType(t)] = int(n)
+			m.Count += int(n)
+		}
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	r.Manifest = m
+	r.Files = append(r.Files, archive.Entry{Name: manifest.Name, Content: data})
+	return nil
+}
@@ -0,0 +1,128 @@
+package outputs
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vikramjakhr/grafana-dashboard-exporter"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/manifest"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/metric"
+)
+
+// sink records the runs handed to it
+type sink struct {
+	runs []*Run
+}
+
+func (s *sink) WriteRun(run *Run) error {
+	s.runs = append(s.runs, run)
+	return nil
+}
+
+func names(run *Run) []string {
+	var n []string
+	for _, f := range run.Files {
+		n = append(n, f.Name)
+	}
+	return n
+}
+
+func TestUniqueName(t *testing.T) {
+	r := &Run{Dir: "MainOrg@2019-April-7T10:00:00"}
+	tests := []struct {
+		name string
+		uid  string
+		want string
+	}{
+		{"Dashboards/API.json", "aaa", "Dashboards/API.json"},
+		{"Dashboards/API.json", "bbb", "Dashboards/API-bbb.json"},
+		{"Dashboards/API.json", "", "Dashboards/API-2.json"},
+		{"Dashboards/API.json", "bbb", "Dashboards/API-3.json"},
+		{"Dashboards/API.json", "a/b", "Dashboards/API-a_b.json"},
+		{"Dashboards/API-2.json", "", "Dashboards/API-2-2.json"},
+		{"Folders/API.json", "aaa", "Folders/API.json"},
+	}
+	for _, tt := range tests {
+		if got := r.uniqueName(tt.name, tt.uid); got != tt.want {
+			t.Errorf("uniqueName(%q, %q) = %q, want %q", tt.name, tt.uid, got, tt.want)
+		}
+	}
+}
+
+func TestAssembler(t *testing.T) {
+	s := &sink{}
+	a := NewAssembler(s)
+	create := func(dir, title, uid string) {
+		m := metric.New(dir, gde.TypeDashboard, gde.ActionCreate, title, "", []byte(`{"title": "`+title+`"}`),
+			gde.Metadata{gde.MetaUID: uid})
+		if err := a.Write(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// two inputs exporting orgs of the same name at once
+	create("MainOrg@2019-April-7T10:00:00", "API", "aaa")
+	create("MainOrg@2019-April-7T10:00:01", "Home", "hhh")
+	create("MainOrg@2019-April-7T10:00:00", "API", "bbb")
+
+	finished := time.Date(2019, time.April, 7, 10, 1, 0, 0, time.UTC)
+	content := []byte(`{"grafana": {"host": "http://grafana"}, "finished": "2019-04-07T10:01:00Z", "failed": 1}`)
+	counts := map[string]int64{string(gde.TypeDashboard): 3}
+	if err := a.Write(metric.New("MainOrg@2019-April-7T10:00:00", "", gde.ActionFinish, "", "", content,
+		gde.Metadata{gde.MetaCounts: counts})); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.runs) != 1 {
+		t.Fatalf("%d runs written, want 1", len(s.runs))
+	}
+	run := s.runs[0]
+	if want := []string{"Dashboards/API.json", "Dashboards/API-bbb.json", manifest.Name}; !reflect.DeepEqual(names(run), want) {
+		t.Errorf("files = %v, want %v", names(run), want)
+	}
+
+	m := run.Manifest
+	if m.Dir != run.Dir || m.Grafana.Host != "http://grafana" || !m.Finished.Equal(finished) || m.Failed != 1 {
+		t.Errorf("manifest = %+v, want the run directory and what the input told", m)
+	}
+	if len(m.Objects) != 2 || m.Objects[1].UID != "bbb" || m.Objects[1].Path != "Dashboards/API-bbb.json" {
+		t.Errorf("manifest objects = %+v", m.Objects)
+	}
+	// the input emitted 3 dashboards, the output only received 2
+	if m.Count != 3 || m.CountByType[gde.TypeDashboard] != 3 {
+		t.Errorf("manifest count = %d, %v, want 3 as emitted by the input", m.Count, m.CountByType)
+	}
+	if problems := m.Verify(map[string][]byte{
+		"Dashboards/API.json":     []byte(`{"title": "API"}`),
+		"Dashboards/API-bbb.json": []byte(`{"title": "API"}`),
+	}); len(problems) != 2 {
+		t.Errorf("Verify() = %v, want the missing dashboard reported", problems)
+	}
+
+	// the other run is still in progress
+	if err := a.Write(metric.New("MainOrg@2019-April-7T10:00:01", "", gde.ActionFinish, "", "", nil, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.runs) != 2 || len(s.runs[1].Files) != 2 {
+		t.Fatalf("second run = %v, want its dashboard and manifest", names(s.runs[1]))
+	}
+	if m := s.runs[1].Manifest; m.Count != 1 || m.CountByType != nil || m.Finished.IsZero() {
+		t.Errorf("manifest without counts = %+v, want the objects received counted", m)
+	}
+}
+
+func TestAssemblerDropsStaleRuns(t *testing.T) {
+	s := &sink{}
+	a := NewAssembler(s)
+	a.Write(metric.New("MainOrg@2019-April-7T10:00:00", gde.TypeDashboard, gde.ActionCreate, "API", "", nil, nil))
+	a.runs["MainOrg@2019-April-7T10:00:00"].updated = time.Now().Add(-2 * staleRun)
+
+	a.Write(metric.New("MainOrg@2019-April-7T11:00:00", gde.TypeDashboard, gde.ActionCreate, "API", "", nil, nil))
+	if _, ok := a.runs["MainOrg@2019-April-7T10:00:00"]; ok {
+		t.Errorf("the stale run was kept")
+	}
+	if _, ok := a.runs["MainOrg@2019-April-7T11:00:00"]; !ok {
+		t.Errorf("the new run was dropped")
+	}
+}
@@ -1,27 +1,66 @@
 package file
 
 import (
-	"archive/zip"
 	"errors"
-	"fmt"
 	"github.com/vikramjakhr/grafana-dashboard-exporter"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/internal/archive"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/internal/encryption"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/internal/retention"
 	"github.com/vikramjakhr/grafana-dashboard-exporter/plugins/outputs"
-	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 type File struct {
-	OutputDir    string `toml:"output_dir"`
-	OutputFormat string `toml:"output_format"`
+	OutputDir        string           `toml:"output_dir"`
+	OutputFormat     string           `toml:"output_format"`
+	CompressionLevel int              `toml:"compression_level"`
+	Naming           string           `toml:"naming"`
+	Retention        retention.Policy `toml:"retention"`
+
+	Encryption encryption.Config `toml:"encryption"`
+
+	runs *outputs.Assembler
 }
 
 var sampleConfig = `
   output_dir = "<dir>" # default is /tmp/gde
-  output_format = "zip" # zip, dir # default is zip
+  output_format = "zip" # zip, tar.gz, tar.zst, dir # default is zip
+  ## Compression level of the archives, 1 (fastest) to 9 for zip and tar.gz
+  ## and to 19 for tar.zst, which requires the zstd binary. 0 is the default
+  ## level of the compression.
+  # compression_level = 0
+
+  ## Naming of the object files: "title" names them after the title
+  ## without spaces, dashboards of a folder being placed under a directory
+  ## named after it. "uid", "slug", "folder/slug" and "title-uid" avoid
+  ## collisions between objects sharing a title.
+  # naming = "title"
+
+  ## Backups older than the retention are removed after every run. A backup
+  ## is kept as soon as one of the rules keeps it, without any rule every
+  ## backup is kept. Rules apply to the backups of each org separately.
+  # [outputs.file.retention]
+  #   keep_last = 10 # keep the 10 most recent backups
+  #   max_age = "720h" # keep the backups of the last 30 days
+  #   keep_daily = 7 # keep the last backup of the 7 most recent days
+  #   keep_weekly = 4 # keep the last backup of the 4 most recent weeks
+  #   keep_monthly = 12 # keep the last backup of the 12 most recent months
+  #   dry_run = false # only log the backups which would be removed
+
+  ## Archives are encrypted before they are written when a method is
+  ## set, "aes-gcm" with a key derived from the passphrase, "age" or
+  ## "openpgp" for the public keys of the recipients. age and openpgp
+  ## require the age and gpg binaries. Recover an archive with "gde decrypt".
+  # [outputs.file.encryption]
+  #   method = "aes-gcm" # aes-gcm, age, openpgp
+  #   passphrase = "$GDE_PASSPHRASE"
+  #   passphrase_file = "/etc/gde/passphrase"
+  #   recipients = ["age1..."] # age public keys or gpg key ids
 `
 
 func (f *File) SampleConfig() string {
@@ -29,148 +68,132 @@ func (f *File) SampleConfig() string {
 }
 
 func (f *File) Connect() error {
+	if err := f.format().Validate(); err != nil {
+		return err
+	}
+	if err := archive.Naming(f.Naming).Validate(); err != nil {
+		return err
+	}
+	f.runs.Naming = archive.Naming(f.Naming)
+	if err := f.Encryption.Validate(); err != nil {
+		return err
+	}
+	if f.Encryption.Enabled() && !f.format().IsArchive() {
+		return errors.New("E! File encryption requires an archive output_format")
+	}
 	if strings.Trim(f.OutputDir, " ") != "" {
 		_, err := os.Stat(f.OutputDir)
 		return err
 	}
-	of := strings.Trim(f.OutputFormat, " ")
-	if !(strings.EqualFold(of, "dir") || strings.EqualFold(of, "zip")) {
-		return errors.New("E! File output_format can only be 'dir' or 'zip' only")
-	}
 	return nil
 }
 
+// format returns the archive format of the runs
+func (f *File) format() archive.Format {
+	return archive.NewFormat(f.OutputFormat, f.CompressionLevel)
+}
+
 func (f *File) Description() string {
 	return "Send grafana json to specified directory"
 }
 
 func (f *File) Write(metric gde.Metric) error {
-	if metric.Action() != "" {
-		dir := "/tmp"
-
-		if strings.Trim(f.OutputDir, " ") != "" {
-			dir = strings.TrimRight(f.OutputDir, "/")
-		}
-
-		baseDir := fmt.Sprintf("%s/%s", dir, metric.Dir())
-		dir = fmt.Sprintf("%s/%ss/", baseDir, string(metric.Type()))
-
-		switch metric.Action() {
-		case gde.ActionCreate:
+	return f.runs.Write(metric)
+}
 
-			if _, err := os.Stat(dir); os.IsNotExist(err) {
-				err = os.MkdirAll(dir, 0774)
-				if err != nil {
-					log.Printf("E! Unable to create direcotry. %v", err)
-					return err
-				}
-			}
+// WriteRun writes the run as an archive, or as a directory of files
+func (f *File) WriteRun(run *outputs.Run) error {
+	dir := "/tmp"
+	if strings.Trim(f.OutputDir, " ") != "" {
+		dir = strings.TrimRight(f.OutputDir, "/")
+	}
+	baseDir := filepath.Join(dir, run.Dir)
 
-			switch metric.Type() {
-			case gde.TypeDatasource:
-				filename := fmt.Sprintf("%s%s.json", dir, strings.Replace(metric.Title(), " ", "", -1))
-				err := ioutil.WriteFile(filename, metric.Content(), 0644)
-				if err != nil {
-					log.Printf("E! Unable to create file. %v", err)
-					return err
-				}
-				break
-			case gde.TypeDashboard:
-				filename := fmt.Sprintf("%s%s.json", dir, strings.Replace(metric.Title(), " ", "", -1))
-				err := ioutil.WriteFile(filename, metric.Content(), 0644)
-				if err != nil {
-					log.Printf("E! Unable to create file. %v", err)
-					return err
-				}
-				break
+	if format := f.format(); format.IsArchive() {
+		target := baseDir + format.Extension() + f.Encryption.Extension()
+		if err := f.writeArchive(target, run.Dir, run.Files); err != nil {
+			log.Printf("E! Unable to create archive. %v", err)
+			return err
+		}
+	} else {
+		for _, file := range run.Files {
+			filename := filepath.Join(baseDir, filepath.FromSlash(file.Name))
+			if err := os.MkdirAll(filepath.Dir(filename), 0774); err != nil {
+				log.Printf("E! Unable to create direcotry. %v", err)
+				return err
 			}
-
-			break
-		case gde.ActionFinish:
-			if strings.EqualFold(f.OutputFormat, "zip") {
-				err := zipit(baseDir, fmt.Sprintf("%s.zip", baseDir))
-				if err != nil {
-					log.Printf("E! Unable to create zip file. %v", err)
-				}
-				log.Printf("D! Clearing the temporary directory")
-				removeDir(baseDir)
+			if err := ioutil.WriteFile(filename, file.Content, 0644); err != nil {
+				log.Printf("E! Unable to create file. %v", err)
+				return err
 			}
-			break
 		}
 	}
-	return nil
-}
 
-func removeDir(dir string) {
-	err := os.RemoveAll(dir)
-	if err != nil {
-		log.Printf("E! Unable to remove directory: %s. %v", dir, err)
+	if f.Retention.Enabled() {
+		f.prune(dir)
 	}
+	return nil
 }
 
-func zipit(source, target string) error {
-	zipfile, err := os.Create(target)
+// writeArchive streams the archive of the run entries to target, through
+// the encryption when enabled. Nothing is left behind on failure.
+func (f *File) writeArchive(target, run string, entries []archive.Entry) error {
+	file, err := os.Create(target)
 	if err != nil {
 		return err
 	}
-	defer zipfile.Close()
-
-	archive := zip.NewWriter(zipfile)
-	defer archive.Close()
-
-	info, err := os.Stat(source)
+	w, err := f.Encryption.Encrypt(file)
+	if err == nil {
+		err = f.format().Write(w, run, entries)
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
 	if err != nil {
-		return nil
+		os.Remove(target)
 	}
+	return err
+}
 
-	var baseDir string
-	if info.IsDir() {
-		baseDir = filepath.Base(source)
+// prune removes the backups in dir expired by the retention policy
+func (f *File) prune(dir string) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		log.Printf("E! Unable to list backups in %s. %v", dir, err)
+		return
 	}
 
-	filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		header, err := zip.FileInfoHeader(info)
-		if err != nil {
-			return err
-		}
-
-		if baseDir != "" {
-			header.Name = filepath.Join(baseDir, strings.TrimPrefix(path, source))
-		}
-
-		if info.IsDir() {
-			header.Name += "/"
-		} else {
-			header.Method = zip.Deflate
-		}
-
-		writer, err := archive.CreateHeader(header)
-		if err != nil {
-			return err
-		}
-
-		if info.IsDir() {
-			return nil
+	var runs []retention.Run
+	for _, info := range infos {
+		if run, ok := retention.ParseRun(info.Name()); ok {
+			runs = append(runs, run)
 		}
+	}
 
-		file, err := os.Open(path)
-		if err != nil {
-			return err
+	for _, run := range f.Retention.Expired(runs, time.Now()) {
+		if f.Retention.DryRun {
+			log.Printf("I! Retention dry run, would remove backup %s", run.Name)
+			continue
 		}
-		defer file.Close()
-		_, err = io.Copy(writer, file)
-		return err
-	})
+		log.Printf("D! Removing backup %s expired by the retention", run.Name)
+		removeDir(filepath.Join(dir, run.Name))
+	}
+}
 
-	return err
+func removeDir(dir string) {
+	err := os.RemoveAll(dir)
+	if err != nil {
+		log.Printf("E! Unable to remove directory: %s. %v", dir, err)
+	}
 }
 
 func init() {
 	outputs.Add("file", func() gde.Output {
-		return &File{}
+		f := &File{}
+		f.runs = outputs.NewAssembler(f)
+		return f
 	})
 }
@@ -0,0 +1,268 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/vikramjakhr/grafana-dashboard-exporter"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/internal/archive"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/manifest"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/plugins/outputs"
+)
+
+type Git struct {
+	RepositoryDir string `toml:"repository_dir"`
+	RemoteURL     string `toml:"remote_url"`
+	Branch        string `toml:"branch"`
+	AuthorName    string `toml:"author_name"`
+	AuthorEmail   string `toml:"author_email"`
+	Naming        string `toml:"naming"`
+
+	runs *outputs.Assembler
+}
+
+var sampleConfig = `
+  repository_dir = "<dir>" # required, working tree of the repository
+  ## Cloned into repository_dir when it is not a repository yet and pushed
+  ## to after every commit, ie, "file:///srv/git/grafana.git"
+  # remote_url = ""
+  branch = "master"
+  author_name = "gde"
+  author_email = "gde@localhost"
+
+  ## Naming of the object files: "title" names them after the title
+  ## without spaces, dashboards of a folder being placed under a directory
+  ## named after it. "uid", "slug", "folder/slug" and "title-uid" avoid
+  ## collisions between objects sharing a title.
+  # naming = "title"
+`
+
+func (g *Git) SampleConfig() string {
+	return sampleConfig
+}
+
+func (g *Git) Description() string {
+	return "Commit grafana json to a git repository, one commit per run"
+}
+
+func (g *Git) Connect() error {
+	if strings.TrimSpace(g.RepositoryDir) == "" {
+		return errors.New("E! Git repository_dir is required")
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		return err
+	}
+	if err := archive.Naming(g.Naming).Validate(); err != nil {
+		return err
+	}
+	g.runs.Naming = archive.Naming(g.Naming)
+	if g.Branch == "" {
+		g.Branch = "master"
+	}
+	if g.AuthorName == "" {
+		g.AuthorName = "gde"
+	}
+	if g.AuthorEmail == "" {
+		g.AuthorEmail = "gde@localhost"
+	}
+
+	if _, err := os.Stat(filepath.Join(g.RepositoryDir, ".git")); err == nil {
+		return nil
+	}
+
+	if g.RemoteURL != "" {
+		log.Printf("D! Cloning %s into %s", g.RemoteURL, g.RepositoryDir)
+		if _, err := g.git("", "clone", g.RemoteURL, g.RepositoryDir); err != nil {
+			return err
+		}
+		// an empty remote has no branch to check out yet
+		_, err := g.git(g.RepositoryDir, "checkout", "-B", g.Branch)
+		return err
+	}
+
+	if err := os.MkdirAll(g.RepositoryDir, 0774); err != nil {
+		return err
+	}
+	log.Printf("D! Initializing git repository in %s", g.RepositoryDir)
+	if _, err := g.git(g.RepositoryDir, "init"); err != nil {
+		return err
+	}
+	_, err := g.git(g.RepositoryDir, "checkout", "-B", g.Branch)
+	return err
+}
+
+func (g *Git) Write(metric gde.Metric) error {
+	return g.runs.Write(metric)
+}
+
+// WriteRun writes the files of the run to the working tree and commits them
+func (g *Git) WriteRun(run *outputs.Run) error {
+	// runs of the same org are written at stable paths, ie, without the
+	// timestamp of the run directory
+	org := run.Org()
+
+	written := make(map[string]bool, len(run.Files))
+	for _, file := range run.Files {
+		// the manifest changes with every run, it would turn every run
+		// into a commit
+		if file.Name == manifest.Name {
+			continue
+		}
+		name := filepath.Join(org, filepath.FromSlash(file.Name))
+		path := filepath.Join(g.RepositoryDir, name)
+
+		if err := os.MkdirAll(filepath.Dir(path), 0774); err != nil {
+			log.Printf("E! Unable to create direcotry. %v", err)
+			return err
+		}
+		if err := ioutil.WriteFile(path, file.Content, 0644); err != nil {
+			log.Printf("E! Unable to create file. %v", err)
+			return err
+		}
+		written[name] = true
+	}
+
+	// objects which failed to export are missing from the run, they must
+	// not be committed as removed
+	prune := run.Manifest == nil || run.Manifest.Failed == 0
+	if !prune {
+		log.Printf("W! %d errors were reported along run %s, keeping the files it did not write",
+			run.Manifest.Failed, run.Dir)
+	}
+	return g.commit(run.Dir, org, written, prune)
+}
+
+// commit removes the files of org which were not written by the run when
+// prune is set, and commits the changes of the run, if any.
+func (g *Git) commit(run, org string, written map[string]bool, prune bool) error {
+	orgDir := filepath.Join(g.RepositoryDir, org)
+	if _, err := os.Stat(orgDir); os.IsNotExist(err) {
+		return nil
+	}
+
+	err := filepath.Walk(orgDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		name, err := filepath.Rel(g.RepositoryDir, path)
+		if err != nil {
+			return err
+		}
+		if prune && !written[name] {
+			log.Printf("D! Removing %s, it is not part of run %s", name, run)
+			return os.Remove(path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := g.git(g.RepositoryDir, "add", "--all", "--", org); err != nil {
+		return err
+	}
+	status, err := g.git(g.RepositoryDir, "diff", "--cached", "--name-status", "-z", "--", org)
+	if err != nil {
+		return err
+	}
+	if status == "" {
+		log.Printf("D! No changes in run %s, skipping commit", run)
+		return nil
+	}
+
+	message := commitMessage(run, status)
+	if _, err := g.git(g.RepositoryDir, "commit", "--quiet", "-m", message, "--", org); err != nil {
+		return err
+	}
+	log.Printf("D! Committed run %s", run)
+
+	if g.RemoteURL != "" {
+		if _, err := g.git(g.RepositoryDir, "push", "--quiet", "origin", g.Branch); err != nil {
+			return err
+		}
+		log.Printf("D! Pushed run %s to %s", run, g.RemoteURL)
+	}
+	return nil
+}
+
+// commitMessage summarizes the output of "git diff --name-status -z", NUL
+// separated status and path fields which keep names with spaces or
+// newlines whole. Renames and copies are followed by both paths.
+func commitMessage(run, status string) string {
+	var added, changed, removed []string
+	fields := strings.Split(strings.TrimSuffix(status, "\x00"), "\x00")
+	for i := 0; i < len(fields)-1; i += 2 {
+		code := fields[i]
+		if code == "" {
+			continue
+		}
+		if code[0] == 'R' || code[0] == 'C' {
+			// the source path comes first, the new one is reported
+			i++
+			if i >= len(fields)-1 {
+				break
+			}
+		}
+		name := fields[i+1]
+		switch code[0] {
+		case 'A':
+			added = append(added, name)
+		case 'D':
+			removed = append(removed, name)
+		default:
+			changed = append(changed, name)
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Backup %s: %d added, %d changed, %d removed\n",
+		run, len(added), len(changed), len(removed))
+	for _, section := range []struct {
+		title string
+		names []string
+	}{{"Added", added}, {"Changed", changed}, {"Removed", removed}} {
+		if len(section.names) == 0 {
+			continue
+		}
+		fmt.Fprintf(&buf, "\n%s:\n", section.title)
+		for _, name := range section.names {
+			fmt.Fprintf(&buf, "  %s\n", name)
+		}
+	}
+	return buf.String()
+}
+
+// git runs a git command in dir and returns its output
+func (g *Git) git(dir string, args ...string) (string, error) {
+	args = append([]string{
+		"-c", "user.name=" + g.AuthorName,
+		"-c", "user.email=" + g.AuthorEmail,
+	}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %v, %s", args[4], err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+func init() {
+	outputs.Add("git", func() gde.Output {
+		g := &Git{}
+		g.runs = outputs.NewAssembler(g)
+		return g
+	})
+}
@@ -0,0 +1,110 @@
+package git
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vikramjakhr/grafana-dashboard-exporter"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/manifest"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/metric"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/plugins/outputs"
+)
+
+func TestCommitMessage(t *testing.T) {
+	status := strings.Join([]string{
+		"A", "MainOrg/Dashboards/New dashboard.json",
+		"M", "MainOrg/Dashboards/Line\nbreak.json",
+		"D", "MainOrg/Folders/Old.json",
+		"R087", "MainOrg/Dashboards/Before.json", "MainOrg/Dashboards/After.json",
+		"T", "MainOrg/Datasources/prom.json",
+	}, "\x00") + "\x00"
+
+	want := "Backup MainOrg@2019-April-7T10:00:00: 1 added, 3 changed, 1 removed\n" +
+		"\nAdded:\n  MainOrg/Dashboards/New dashboard.json\n" +
+		"\nChanged:\n  MainOrg/Dashboards/Line\nbreak.json\n  MainOrg/Dashboards/After.json\n  MainOrg/Datasources/prom.json\n" +
+		"\nRemoved:\n  MainOrg/Folders/Old.json\n"
+	if got := commitMessage("MainOrg@2019-April-7T10:00:00", status); got != want {
+		t.Errorf("commitMessage() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+// repository returns a git output committing to a new repository
+func repository(t *testing.T) (*Git, func()) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := ioutil.TempDir("", "gde-git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &Git{RepositoryDir: dir}
+	g.runs = outputs.NewAssembler(g)
+	if err := g.Connect(); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return g, func() { os.RemoveAll(dir) }
+}
+
+// run writes the dashboards of a run to g, failed being the number of
+// errors its input reported
+func run(t *testing.T, g *Git, dir string, failed int, titles ...string) {
+	for _, title := range titles {
+		content := []byte(`{"dashboard": {"title": "` + title + `"}}`)
+		if err := g.Write(metric.New(dir, gde.TypeDashboard, gde.ActionCreate, title, "", content, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	content := []byte(fmt.Sprintf(`{"failed": %d}`, failed))
+	if err := g.Write(metric.New(dir, "", gde.ActionFinish, "", "", content, nil)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func files(t *testing.T, g *Git) []string {
+	out, err := g.git(g.RepositoryDir, "ls-files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Fields(out)
+}
+
+func TestWriteRunPrune(t *testing.T) {
+	g, cleanup := repository(t)
+	defer cleanup()
+
+	run(t, g, "MainOrg@2019-April-7T10:00:00", 0, "A", "B")
+	want := []string{"MainOrg/Dashboards/A.json", "MainOrg/Dashboards/B.json"}
+	if got := files(t, g); !reflect.DeepEqual(got, want) {
+		t.Fatalf("files = %v, want %v", got, want)
+	}
+
+	// B failing to export is not a removal
+	run(t, g, "MainOrg@2019-April-7T11:00:00", 1, "A")
+	if got := files(t, g); !reflect.DeepEqual(got, want) {
+		t.Errorf("files after a failed run = %v, want %v", got, want)
+	}
+
+	run(t, g, "MainOrg@2019-April-7T12:00:00", 0, "A")
+	want = []string{"MainOrg/Dashboards/A.json"}
+	if got := files(t, g); !reflect.DeepEqual(got, want) {
+		t.Errorf("files after B was removed = %v, want %v", got, want)
+	}
+
+	log, err := g.git(g.RepositoryDir, "log", "--format=%s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commits := strings.Count(log, "\n"); commits != 2 {
+		t.Errorf("%d commits, want 2:\n%s", commits, log)
+	}
+	if _, err := os.Stat(filepath.Join(g.RepositoryDir, "MainOrg", manifest.Name)); !os.IsNotExist(err) {
+		t.Errorf("the manifest was written to the repository")
+	}
+}
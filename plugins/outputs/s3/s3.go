@@ -5,18 +5,20 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 
-	"archive/zip"
+	"bytes"
 	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/vikramjakhr/grafana-dashboard-exporter"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/internal/archive"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/internal/encryption"
+	"github.com/vikramjakhr/grafana-dashboard-exporter/internal/retention"
 	"github.com/vikramjakhr/grafana-dashboard-exporter/plugins/outputs"
 	"io"
-	"io/ioutil"
 	"log"
-	"os"
-	"path/filepath"
+	"path"
 	"strings"
+	"time"
 )
 
 type S3 struct {
@@ -26,6 +28,19 @@ type S3 struct {
 	Region       string `toml:"region"`
 	BucketPrefix string `toml:"bucket_prefix"`
 	OutputFormat string `toml:"output_format"`
+
+	CompressionLevel int    `toml:"compression_level"`
+	Naming           string `toml:"naming"`
+
+	// Endpoint and ForcePathStyle allow to use an S3 compatible storage
+	Endpoint       string `toml:"endpoint"`
+	ForcePathStyle bool   `toml:"force_path_style"`
+
+	Retention retention.Policy `toml:"retention"`
+
+	Encryption encryption.Config `toml:"encryption"`
+
+	runs *outputs.Assembler
 }
 
 var sampleConfig = `
@@ -34,7 +49,41 @@ var sampleConfig = `
   secret_key = "$SECRET_KEY" # required
   region = "s3-bucket-region"
   bucketPrefix = "<prefix>"
-  output_format = "zip" # zip, dir
+  output_format = "zip" # zip, tar.gz, tar.zst, dir
+  ## Compression level of the archives, 1 (fastest) to 9 for zip and tar.gz
+  ## and to 19 for tar.zst, which requires the zstd binary. 0 is the default
+  ## level of the compression.
+  # compression_level = 0
+
+  ## Naming of the object files: "title" names them after the title
+  ## without spaces, dashboards of a folder being placed under a directory
+  ## named after it. "uid", "slug", "folder/slug" and "title-uid" avoid
+  ## collisions between objects sharing a title.
+  # naming = "title"
+
+  ## Endpoint of an S3 compatible storage, ie, "http://localhost:9000".
+  ## Such storages mostly require path style addressing.
+  # endpoint = ""
+  # force_path_style = false
+
+  ## Backups under bucket_prefix older than the retention are deleted after
+  ## every successful upload. A backup is kept as soon as one of the rules
+  ## keeps it, without any rule every backup is kept. Rules apply to the
+  ## backups of each org separately.
+  # [outputs.s3.retention]
+  #   keep_last = 10 # keep the 10 most recent backups
+  #   max_age = "720h" # keep the backups of the last 30 days
+  #   dry_run = false # only log the backups which would be deleted
+
+  ## Archives are encrypted before they are uploaded when a method is
+  ## set, "aes-gcm" with a key derived from the passphrase, "age" or
+  ## "openpgp" for the public keys of the recipients. age and openpgp
+  ## require the age and gpg binaries. Recover an archive with "gde decrypt".
+  # [outputs.s3.encryption]
+  #   method = "aes-gcm" # aes-gcm, age, openpgp
+  #   passphrase = "$GDE_PASSPHRASE"
+  #   passphrase_file = "/etc/gde/passphrase"
+  #   recipients = ["age1..."] # age public keys or gpg key ids
 `
 
 func (f *S3) SampleConfig() string {
@@ -42,15 +91,24 @@ func (f *S3) SampleConfig() string {
 }
 
 func (f *S3) Connect() error {
-	of := strings.Trim(f.OutputFormat, " ")
-	if !(strings.EqualFold(of, "dir") || strings.EqualFold(of, "zip")) {
-		return errors.New("E! S3 output_format can only be 'file' or 'zip' only")
+	if err := f.format().Validate(); err != nil {
+		return err
+	}
+	if err := archive.Naming(f.Naming).Validate(); err != nil {
+		return err
+	}
+	f.runs.Naming = archive.Naming(f.Naming)
+	if err := f.Encryption.Validate(); err != nil {
+		return err
+	}
+	if f.Encryption.Enabled() && !f.format().IsArchive() {
+		return errors.New("E! S3 encryption requires an archive output_format")
 	}
 
-	sess, err := session.NewSession(&aws.Config{
-		Region:      aws.String(f.Region),
-		Credentials: credentials.NewStaticCredentials(f.AccessKey, f.SecretKey, ""),
-	})
+	sess, err := f.makeSession()
+	if err != nil {
+		return err
+	}
 
 	// Create S3 service client
 	svc := s3.New(sess)
@@ -61,238 +119,264 @@ func (f *S3) Connect() error {
 	return nil
 }
 
+// format returns the archive format of the runs
+func (f *S3) format() archive.Format {
+	return archive.NewFormat(f.OutputFormat, f.CompressionLevel)
+}
+
 func (f *S3) Description() string {
 	return "Send grafana json to s3"
 }
 
 func (f *S3) Write(metric gde.Metric) error {
-	if metric.Action() != "" {
-		dir := "/tmp/gde"
-
-		baseDir := fmt.Sprintf("%s/%s", dir, metric.Dir())
-		dir = fmt.Sprintf("%s/%ss/", baseDir, string(metric.Type()))
+	return f.runs.Write(metric)
+}
 
-		switch metric.Action() {
-		case gde.ActionCreate:
+// WriteRun uploads the run as an archive, or as one object per file
+func (f *S3) WriteRun(run *outputs.Run) error {
+	sess, err := f.makeSession()
+	if err != nil {
+		return errors.New(fmt.Sprintf("E! failed to create aws session, %v", err))
+	}
+	svc := s3.New(sess)
 
-			if _, err := os.Stat(dir); os.IsNotExist(err) {
-				err = os.MkdirAll(dir, 0774)
-				if err != nil {
-					log.Printf("E! Unable to create direcotry. %v", err)
-					return err
-				}
+	if format := f.format(); format.IsArchive() {
+		key := f.key(run.Dir + format.Extension() + f.Encryption.Extension())
+		if err := f.uploadArchive(svc, key, run.Dir, run.Files); err != nil {
+			return errors.New(fmt.Sprintf("E! Failed to upload data to %s/%s, %s\n",
+				f.Bucket, key, err))
+		}
+		log.Printf("D! %s uploaded to s3", key)
+	} else {
+		for _, file := range run.Files {
+			key := f.key(path.Join(run.Dir, file.Name))
+			if err := upload(svc, f.Bucket, key, bytes.NewReader(file.Content)); err != nil {
+				return errors.New(fmt.Sprintf("E! Failed to upload data to %s/%s, %s\n",
+					f.Bucket, key, err))
 			}
+		}
+		log.Printf("D! %s uploaded to s3", f.key(run.Dir))
+	}
+	f.applyRetention(sess)
+	return nil
+}
 
-			switch metric.Type() {
-			case gde.TypeDatasource:
-				filename := fmt.Sprintf("%s%s.json", dir, strings.Replace(metric.Title(), " ", "", -1))
-				err := ioutil.WriteFile(filename, metric.Content(), 0644)
-				if err != nil {
-					log.Printf("E! Unable to create file. %v", err)
-					return err
-				}
-				break
-			case gde.TypeDashboard:
-				filename := fmt.Sprintf("%s%s.json", dir, strings.Replace(metric.Title(), " ", "", -1))
-				err := ioutil.WriteFile(filename, metric.Content(), 0644)
-				if err != nil {
-					log.Printf("E! Unable to create file. %v", err)
-					return err
-				}
-				break
-			}
+// key returns the key of name under the bucket prefix
+func (f *S3) key(name string) string {
+	prefix := strings.Trim(f.BucketPrefix, "/")
+	if prefix == "" {
+		return name
+	}
+	return prefix + "/" + name
+}
 
-			break
-		case gde.ActionFinish:
-			if strings.EqualFold(f.OutputFormat, "zip") {
-				zipFileName := fmt.Sprintf("%s.zip", baseDir)
-				err := zipit(baseDir, zipFileName)
-				if err != nil {
-					removeDir(dir)
-					log.Printf("E! Unable to create zip file. %v", err)
-					return err
-				} else {
-					sess, err := f.makeSession()
-					if err != nil {
-						removeDir(dir)
-						return errors.New(fmt.Sprintf("E! failed to create aws session, %v", err))
-					}
-					err = uploadFileToS3(sess, f.Bucket, f.BucketPrefix, zipFileName)
-					if err != nil {
-						removeDir(dir)
-						return errors.New(fmt.Sprintf("E! Failed to upload data to %s/%s, %s\n",
-							f.Bucket, zipFileName, err))
-					}
-					log.Printf("D! %s uploaded to s3", zipFileName)
-					removeDir(dir)
-				}
-			}
-			if strings.EqualFold(f.OutputFormat, "dir") {
-				sess, err := f.makeSession()
-				if err != nil {
-					removeDir(dir)
-					return errors.New(fmt.Sprintf("E! failed to create aws session, %v", err))
-				}
-				err = uploadDirToS3(sess, f.Bucket, f.BucketPrefix, baseDir)
-				if err != nil {
-					removeDir(dir)
-					return errors.New(fmt.Sprintf("E! Failed to upload data to %s/%s, %s\n",
-						f.Bucket, baseDir, err))
-				}
-				log.Printf("D! %s uploaded to s3", baseDir)
-				removeDir(dir)
+// uploadArchive streams the archive of the run entries to key, through the
+// encryption when enabled, without staging it on disk
+func (f *S3) uploadArchive(svc *s3.S3, key, run string, entries []archive.Entry) error {
+	pr, pw := io.Pipe()
+	go func() {
+		w, err := f.Encryption.Encrypt(pw)
+		if err == nil {
+			err = f.format().Write(w, run, entries)
+			if cerr := w.Close(); err == nil {
+				err = cerr
 			}
-			break
 		}
-	}
-	return nil
+		pw.CloseWithError(err)
+	}()
+
+	err := upload(svc, f.Bucket, key, pr)
+	// unblocks the archive writer when the upload failed midway
+	pr.CloseWithError(err)
+	return err
 }
 
 func (f *S3) makeSession() (*session.Session, error) {
-	sess, err := session.NewSession(&aws.Config{
+	config := &aws.Config{
 		Region:      aws.String(f.Region),
 		Credentials: credentials.NewStaticCredentials(f.AccessKey, f.SecretKey, ""),
-	})
+	}
+	if f.Endpoint != "" {
+		config.Endpoint = aws.String(f.Endpoint)
+	}
+	if f.ForcePathStyle {
+		config.S3ForcePathStyle = aws.Bool(true)
+	}
+	sess, err := session.NewSession(config)
 	if err != nil {
 		return nil, err
 	}
 	return sess, nil
 }
 
-func uploadDirToS3(sess *session.Session, bucketName string, bucketPrefix string, dirPath string) error {
-	fileList := []string{}
-	filepath.Walk(dirPath, func(path string, f os.FileInfo, err error) error {
-		if isDirectory(path) {
-			// Do nothing
-			return nil
-		} else {
-			fileList = append(fileList, path)
-			return nil
-		}
-	})
-
-	for _, file := range fileList {
-		err := uploadFileToS3(sess, bucketName, bucketPrefix, file)
-		if err != nil {
-			return err
-		}
+// applyRetention deletes the backups under the bucket prefix expired by the
+// retention policy
+func (f *S3) applyRetention(sess *session.Session) {
+	if !f.Retention.Enabled() {
+		return
+	}
+	if err := f.prune(sess); err != nil {
+		log.Printf("E! Unable to apply the retention to %s/%s. %v", f.Bucket, f.BucketPrefix, err)
 	}
-	return nil
 }
 
-func isDirectory(path string) bool {
-	fd, err := os.Stat(path)
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(2)
+func (f *S3) prune(sess *session.Session) error {
+	svc := s3.New(sess)
+	prefix := strings.Trim(f.BucketPrefix, "/")
+
+	// keys of every backup, zip backups are a single object while dir
+	// backups are one object per file
+	keys := make(map[string][]string)
+	var runs []retention.Run
+	input := &s3.ListObjectsInput{Bucket: aws.String(f.Bucket)}
+	if prefix != "" {
+		input.Prefix = aws.String(prefix + "/")
 	}
-	switch mode := fd.Mode(); {
-	case mode.IsDir():
+	err := svc.ListObjectsPages(input, func(page *s3.ListObjectsOutput, lastPage bool) bool {
+		for _, o := range page.Contents {
+			name := runName(prefix, aws.StringValue(o.Key))
+			run, ok := retention.ParseRun(name)
+			if !ok {
+				continue
+			}
+			if _, seen := keys[name]; !seen {
+				runs = append(runs, run)
+			}
+			keys[name] = append(keys[name], aws.StringValue(o.Key))
+		}
 		return true
-	case mode.IsRegular():
-		return false
-	}
-	return false
-}
-
-func uploadFileToS3(sess *session.Session, bucketName string, bucketPrefix string, filePath string) error {
-	log.Printf("D! uploading %s to S3", filePath)
-	// An s3 service
-	s3Svc := s3.New(sess)
-	file, err := os.Open(filePath)
+	})
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-
-	var key string
-	fileDirectory, _ := filepath.Abs(filePath)
-	key = bucketPrefix + "/" + strings.TrimPrefix(fileDirectory, "/tmp/gde")
 
-	// Upload the file to the s3 given bucket
-	params := &s3.PutObjectInput{
-		Bucket: aws.String(bucketName), // Required
-		Key:    aws.String(key),        // Required
-		Body:   file,
-	}
-	_, err = s3Svc.PutObject(params)
-	if err != nil {
-		return err
+	for _, run := range f.Retention.Expired(runs, time.Now()) {
+		if f.Retention.DryRun {
+			log.Printf("I! Retention dry run, would delete backup %s (%d objects) from %s",
+				run.Name, len(keys[run.Name]), f.Bucket)
+			continue
+		}
+		log.Printf("D! Deleting backup %s expired by the retention", run.Name)
+		if err := deleteKeys(svc, f.Bucket, keys[run.Name]); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func removeDir(dir string) {
-	log.Printf("D! Clearing the directory: %s", dir)
-	err := os.RemoveAll(dir)
-	if err != nil {
-		log.Printf("E! Unable to remove directory: %s. %v", dir, err)
+// runName returns the backup name of a key, the first path element after
+// the bucket prefix
+func runName(prefix, key string) string {
+	key = strings.TrimLeft(key, "/")
+	key = strings.TrimLeft(strings.TrimPrefix(key, prefix), "/")
+	return strings.SplitN(key, "/", 2)[0]
+}
+
+// deleteKeys deletes keys in batches of the 1000 keys allowed per request
+func deleteKeys(svc *s3.S3, bucket string, keys []string) error {
+	for len(keys) > 0 {
+		n := len(keys)
+		if n > 1000 {
+			n = 1000
+		}
+		objects := make([]*s3.ObjectIdentifier, 0, n)
+		for _, key := range keys[:n] {
+			objects = append(objects, &s3.ObjectIdentifier{Key: aws.String(key)})
+		}
+		out, err := svc.DeleteObjects(&s3.DeleteObjectsInput{
+			Bucket: aws.String(bucket),
+			Delete: &s3.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return err
+		}
+		if len(out.Errors) > 0 {
+			return fmt.Errorf("unable to delete %s, %s", aws.StringValue(out.Errors[0].Key),
+				aws.StringValue(out.Errors[0].Message))
+		}
+		keys = keys[n:]
 	}
+	return nil
 }
 
-func zipit(source, target string) error {
-	zipfile, err := os.Create(target)
+// partSize is the size of the parts of multipart uploads, the minimum
+// allowed by S3
+const partSize = 5 * 1024 * 1024
+
+// upload uploads the content of r to key, with a single request when it is
+// smaller than a part and as a multipart upload otherwise, so that only a
+// part is held in memory at a time
+func upload(svc *s3.S3, bucket, key string, r io.Reader) error {
+	log.Printf("D! uploading %s to S3", key)
+	buf := make([]byte, partSize)
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		_, err = svc.PutObject(&s3.PutObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+			Body:   bytes.NewReader(buf[:n]),
+		})
+		return err
+	}
 	if err != nil {
 		return err
 	}
-	defer zipfile.Close()
-
-	archive := zip.NewWriter(zipfile)
-	defer archive.Close()
 
-	info, err := os.Stat(source)
+	create, err := svc.CreateMultipartUpload(&s3.CreateMultipartUploadInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
 	if err != nil {
-		return nil
+		return err
 	}
-
-	var baseDir string
-	if info.IsDir() {
-		baseDir = filepath.Base(source)
+	abort := func(err error) error {
+		svc.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(bucket),
+			Key:      aws.String(key),
+			UploadId: create.UploadId,
+		})
+		return err
 	}
 
-	filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+	var parts []*s3.CompletedPart
+	for number := int64(1); ; number++ {
+		out, err := svc.UploadPart(&s3.UploadPartInput{
+			Bucket:     aws.String(bucket),
+			Key:        aws.String(key),
+			UploadId:   create.UploadId,
+			PartNumber: aws.Int64(number),
+			Body:       bytes.NewReader(buf[:n]),
+		})
 		if err != nil {
-			return err
+			return abort(err)
 		}
+		parts = append(parts, &s3.CompletedPart{ETag: out.ETag, PartNumber: aws.Int64(number)})
 
-		header, err := zip.FileInfoHeader(info)
-		if err != nil {
-			return err
-		}
-
-		if baseDir != "" {
-			header.Name = filepath.Join(baseDir, strings.TrimPrefix(path, source))
-		}
-
-		if info.IsDir() {
-			header.Name += "/"
-		} else {
-			header.Method = zip.Deflate
-		}
-
-		writer, err := archive.CreateHeader(header)
-		if err != nil {
-			return err
+		n, err = io.ReadFull(r, buf)
+		if err == io.EOF {
+			break
 		}
-
-		if info.IsDir() {
-			return nil
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return abort(err)
 		}
+	}
 
-		file, err := os.Open(path)
-		if err != nil {
-			return err
-		}
-		defer file.Close()
-		_, err = io.Copy(writer, file)
-		return err
+	_, err = svc.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucket),
+		Key:             aws.String(key),
+		UploadId:        create.UploadId,
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
 	})
-
-	return err
+	if err != nil {
+		return abort(err)
+	}
+	return nil
 }
 
 func init() {
 	outputs.Add("s3", func() gde.Output {
-		return &S3{}
+		f := &S3{}
+		f.runs = outputs.NewAssembler(f)
+		return f
 	})
 }
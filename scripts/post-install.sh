@@ -2,6 +2,7 @@
 
 BIN_DIR=/usr/bin
 LOG_DIR=/var/log/gde
+DATA_DIR=/var/lib/gde
 SCRIPT_DIR=/usr/lib/gde/scripts
 LOGROTATE_DIR=/etc/logrotate.d
 
@@ -36,6 +37,10 @@ test -d $LOG_DIR || mkdir -p $LOG_DIR
 chown -R -L gde:gde $LOG_DIR
 chmod 755 $LOG_DIR
 
+test -d $DATA_DIR || mkdir -p $DATA_DIR
+chown -R -L gde:gde $DATA_DIR
+chmod 755 $DATA_DIR
+
 # Remove legacy symlink, if it exists
 if [[ -L /etc/init.d/gde ]]; then
     rm -f /etc/init.d/gde